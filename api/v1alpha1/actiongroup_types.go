@@ -0,0 +1,82 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ActionGroupSpec defines the desired state of ActionGroup
+type ActionGroupSpec struct {
+	AllowedActions []string `json:"allowed_actions"`
+	//+kubebuilder:validation:Enum=cluster;index;kibana
+	//+optional
+	Type string `json:"type,omitempty"`
+	//+optional
+	Description string `json:"description,omitempty"`
+	// Take over existing elasticsearch action group, which is not managed by this resource
+	//+optional
+	Adopt bool `json:"adopt,omitempty"`
+	// What to do with elasticsearch action group, when resource is deleted. Operator default is used, if not set
+	//+kubebuilder:validation:Enum=Delete;RetainTenants;Retain;Orphan
+	//+optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// ActionGroupStatus defines the observed state of ActionGroup
+type ActionGroupStatus struct {
+	Status string `json:"state"`
+	//+optional
+	Error string `json:"error,omitempty"`
+	// Generation of resource, which was successfully synchronized with elasticsearch
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conflict condition is true, when elasticsearch object or its name belongs to another resource or object wasn't created by operator
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+
+// ActionGroup is the Schema for the actiongroups API. Action groups are global in elasticsearch,
+// so resource is cluster-scoped and its name is the name of action group
+type ActionGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ActionGroupSpec   `json:"spec,omitempty"`
+	Status ActionGroupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ActionGroupList contains a list of ActionGroup
+type ActionGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ActionGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ActionGroup{}, &ActionGroupList{})
+}
@@ -29,17 +29,34 @@ type AlertSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	Name string `json:"name"`
-	//+kubebuilder:default:monitor
-	Type     string           `json:"type"`
+	//+kubebuilder:default:=monitor
+	//+optional
+	Type string `json:"type"`
+	//+kubebuilder:default:=true
+	//+optional
 	Enabled  bool             `json:"enabled"`
 	Schedule MonitorSchedule  `json:"schedule"`
 	Inputs   []MonitorInput   `json:"inputs"`
 	Triggers []MonitorTrigger `json:"triggers"`
+	// Execute monitor with dryrun before saving it and refuse to deploy it, if scripts fail
+	//+optional
+	ValidateOnApply bool `json:"validateOnApply,omitempty"`
+	// Take over existing monitor with the same name, which is not managed by this resource
+	//+optional
+	Adopt bool `json:"adopt,omitempty"`
+	// What to do with elasticsearch objects, when resource is deleted. Operator default is used, if not set
+	//+kubebuilder:validation:Enum=Delete;RetainTenants;Retain;Orphan
+	//+optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+	// Active alert IDs to acknowledge or "all-active" to acknowledge every active alert. Alerts are acknowledged once after spec change
+	//+optional
+	Acknowledge []string `json:"acknowledge,omitempty"`
 }
 
 // MonitorTrigger defines triggers and required actions
 type MonitorTrigger struct {
-	Name      string           `json:"name"`
+	Name string `json:"name"`
+	//+optional
 	Severity  string           `json:"severity"`
 	Condition TriggerCondition `json:"condition"`
 	Actions   []TriggerAction  `json:"actions"`
@@ -52,19 +69,20 @@ type TriggerAction struct {
 	Destination     string       `json:"destination_id"`
 	SubjectTemplate TextTemplate `json:"subject_template"`
 	MessageTemplate TextTemplate `json:"message_template"`
-	// TODO:
 	//+optional
-	//ThrottleEnabled bool `json:"throttle_enabled,omitempty"`
+	ThrottleEnabled bool `json:"throttle_enabled,omitempty"`
 	//+optional
-	//Throttle TriggerThrottle `json:"throttle,omitempty"`
+	Throttle *TriggerThrottle `json:"throttle,omitempty"`
 }
 
 // TriggerThrottle defines alerting throttle
 type TriggerThrottle struct {
 	//+kubebuilder:default:=1
+	//+optional
 	Value int `json:"value"`
 	//+kubebuilder:default:=MINUTES
 	//+kubebuilder:validation:Enum=HOURS;MINUTES;DAYS
+	//+optional
 	Unit string `json:"unit"`
 }
 
@@ -72,6 +90,7 @@ type TriggerThrottle struct {
 type TextTemplate struct {
 	Source string `json:"source"`
 	//+kubebuilder:validation:Enum=mustache;painless
+	//+optional
 	Lang string `json:"lang"`
 }
 
@@ -84,6 +103,7 @@ type TriggerCondition struct {
 type ConditionScript struct {
 	Source string `json:"source"`
 	//+kubebuilder:validation:Enum=painless
+	//+optional
 	Lang string `json:"lang"`
 }
 
@@ -108,12 +128,29 @@ type MonitorSchedule struct {
 type SchedulePeroid struct {
 	Interval int `json:"interval"`
 	//+kubebuilder:validation:Enum=HOURS;MINUTES;DAYS
+	//+optional
 	Unit string `json:"unit"`
 }
 
 // AlertStatus defines the observed state of Alert
 type AlertStatus struct {
 	Monitor StatusMonitor `json:"monitor"`
+	// Generation of resource, which was successfully synchronized with elasticsearch
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	//+optional
+	Validation *StatusValidation `json:"validation,omitempty"`
+	//+optional
+	Alerts *StatusAlerts `json:"alerts,omitempty"`
+	//+optional
+	Acknowledgement *StatusAcknowledgement `json:"acknowledgement,omitempty"`
+	//+optional
+	Templates []StatusTemplatePreview `json:"templates,omitempty"`
+	// Conflict condition is true, when elasticsearch object or its name belongs to another resource or object wasn't created by operator
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 // StatusMonitor defines alert's status
@@ -125,10 +162,75 @@ type StatusMonitor struct {
 	Error string `json:"error,omitempty"`
 }
 
+// StatusValidation defines result of monitor dry run
+type StatusValidation struct {
+	Compiled bool `json:"compiled"`
+	//+optional
+	Error string `json:"error,omitempty"`
+	//+optional
+	InputError string `json:"inputError,omitempty"`
+	//+optional
+	Triggers []StatusTriggerValidation `json:"triggers,omitempty"`
+}
+
+// StatusTriggerValidation defines result of trigger condition dry run
+type StatusTriggerValidation struct {
+	Name      string `json:"name"`
+	Triggered bool   `json:"triggered"`
+	//+optional
+	Error string `json:"error,omitempty"`
+}
+
+// StatusAlerts defines current state of alerts, generated by monitor
+type StatusAlerts struct {
+	// ACTIVE, ACKNOWLEDGED, COMPLETED or ERROR, based on the most important alert
+	//+optional
+	State        string `json:"state,omitempty"`
+	Active       int    `json:"active"`
+	Acknowledged int    `json:"acknowledged"`
+	// Highest severity of active and acknowledged alerts
+	//+optional
+	Severity string `json:"severity,omitempty"`
+	//+optional
+	LastTriggered *metav1.Time `json:"lastTriggered,omitempty"`
+}
+
+// StatusAcknowledgement defines result of the last alerts acknowledgement
+type StatusAcknowledgement struct {
+	Time metav1.Time `json:"time"`
+	// Generation of resource, which spec.acknowledge was applied. Spec is applied once, alerts, which become active later, are not acknowledged
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	//+optional
+	Alerts []string `json:"alerts,omitempty"`
+	//+optional
+	Failed []string `json:"failed,omitempty"`
+}
+
+// StatusTemplatePreview defines trigger action templates, rendered with sample context
+type StatusTemplatePreview struct {
+	Trigger string `json:"trigger"`
+	Action  string `json:"action"`
+	//+optional
+	Subject string `json:"subject,omitempty"`
+	//+optional
+	Message string `json:"message,omitempty"`
+	//+optional
+	Error string `json:"error,omitempty"`
+	// Optional variables, which are empty in some executions, e.g. fields of ctx.alert outside of its section
+	//+optional
+	Warnings []string `json:"warnings,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Enabled",type=boolean,JSONPath=`.spec.enabled`
 //+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.monitor.state`
+//+kubebuilder:printcolumn:name="Monitor",type=string,JSONPath=`.status.monitor.name`,priority=1
+//+kubebuilder:printcolumn:name="Alert state",type=string,JSONPath=`.status.alerts.state`
+//+kubebuilder:printcolumn:name="Active",type=integer,JSONPath=`.status.alerts.active`
+//+kubebuilder:printcolumn:name="Severity",type=string,JSONPath=`.status.alerts.severity`
+//+kubebuilder:printcolumn:name="Last triggered",type=date,JSONPath=`.status.alerts.lastTriggered`
 
 // Alert is the Schema for the alerts API
 type Alert struct {
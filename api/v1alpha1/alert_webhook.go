@@ -0,0 +1,143 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var alertlog = logf.Log.WithName("alert-resource")
+
+// SetupWebhookWithManager register Alert webhooks in manager
+func (r *Alert) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-security-rshbdev-ru-v1alpha1-alert,mutating=true,failurePolicy=fail,sideEffects=None,groups=security.rshbdev.ru,resources=alerts,verbs=create;update,versions=v1alpha1,name=malert.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Defaulter = &Alert{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Alert) Default() {
+	alertlog.Info("default", "name", r.Name)
+	if r.Spec.Type == "" {
+		r.Spec.Type = "monitor"
+	}
+	if r.Spec.Schedule.Period.Unit == "" {
+		r.Spec.Schedule.Period.Unit = "MINUTES"
+	}
+	for i := range r.Spec.Triggers {
+		trigger := &r.Spec.Triggers[i]
+		if trigger.Severity == "" {
+			trigger.Severity = "1"
+		}
+		if trigger.Condition.Script.Lang == "" {
+			trigger.Condition.Script.Lang = "painless"
+		}
+		for j := range trigger.Actions {
+			action := &trigger.Actions[j]
+			if action.SubjectTemplate.Lang == "" {
+				action.SubjectTemplate.Lang = "mustache"
+			}
+			if action.MessageTemplate.Lang == "" {
+				action.MessageTemplate.Lang = "mustache"
+			}
+			if action.Throttle != nil {
+				if action.Throttle.Value == 0 {
+					action.Throttle.Value = 1
+				}
+				if action.Throttle.Unit == "" {
+					action.Throttle.Unit = "MINUTES"
+				}
+			}
+		}
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-security-rshbdev-ru-v1alpha1-alert,mutating=false,failurePolicy=fail,sideEffects=None,groups=security.rshbdev.ru,resources=alerts,verbs=create;update,versions=v1alpha1,name=valert.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &Alert{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Alert) ValidateCreate() error {
+	alertlog.Info("validate create", "name", r.Name)
+	return r.validateAlert()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Alert) ValidateUpdate(old runtime.Object) error {
+	alertlog.Info("validate update", "name", r.Name)
+	// Deletion, finalizer removal and metadata updates must not be blocked by validation
+	oldAlert, ok := old.(*Alert)
+	if r.DeletionTimestamp != nil || ok && equality.Semantic.DeepEqual(oldAlert.Spec, r.Spec) {
+		return nil
+	}
+	return r.validateAlert()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Alert) ValidateDelete() error {
+	return nil
+}
+
+func (r *Alert) validateAlert() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if r.Spec.Name == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("name"), "monitor name is required"))
+	}
+	if r.Spec.Schedule.Period.Interval <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("schedule", "period", "interval"), r.Spec.Schedule.Period.Interval, "must be greater than 0"))
+	}
+	if len(r.Spec.Inputs) == 0 {
+		allErrs = append(allErrs, field.Required(specPath.Child("inputs"), "at least one input is required"))
+	}
+	for i, input := range r.Spec.Inputs {
+		path := specPath.Child("inputs").Index(i).Child("search")
+		allErrs = append(allErrs, validateNotEmptyStrings(path.Child("indices"), input.Search.Indices)...)
+		if err := validateJSON(path.Child("query"), input.Search.Query); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if len(r.Spec.Triggers) == 0 {
+		allErrs = append(allErrs, field.Required(specPath.Child("triggers"), "at least one trigger is required"))
+	}
+	for i, trigger := range r.Spec.Triggers {
+		path := specPath.Child("triggers").Index(i)
+		if trigger.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("name"), "trigger name is required"))
+		}
+		if trigger.Condition.Script.Source == "" {
+			allErrs = append(allErrs, field.Required(path.Child("condition", "script", "source"), "condition script is required"))
+		}
+		for j, action := range trigger.Actions {
+			actionPath := path.Child("actions").Index(j)
+			if action.Name == "" {
+				allErrs = append(allErrs, field.Required(actionPath.Child("name"), "action name is required"))
+			}
+		}
+	}
+	return invalidError("Alert", r.Name, allErrs)
+}
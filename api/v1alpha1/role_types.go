@@ -22,6 +22,18 @@ import (
 
 // RoleSpec defines the desired state of Role
 type RoleSpec struct {
+	// Explicit name of elasticsearch role, overrides operator naming strategy. Not used by RoleTemplate
+	//+optional
+	Name string `json:"name,omitempty"`
+	//+optional
+	Description string `json:"description,omitempty"`
+	// Take over existing elasticsearch role, which is not managed by this resource
+	//+optional
+	Adopt bool `json:"adopt,omitempty"`
+	// What to do with elasticsearch objects, when resource is deleted. Operator default is used, if not set
+	//+kubebuilder:validation:Enum=Delete;RetainTenants;Retain;Orphan
+	//+optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
 	//+optional
 	ClusterPermissons []string           `json:"cluster_permissions,omitempty"`
 	IndexPermissions  []IndexPermissions `json:"index_permissions"`
@@ -59,6 +71,30 @@ type TenantPermissions struct {
 // RoleStatus defines the observed state of Role
 type RoleStatus struct {
 	Status string `json:"state"`
+	// Name of elasticsearch role, managed by this resource
+	//+optional
+	Name string `json:"name,omitempty"`
+	// Generation of resource, which was successfully synchronized with elasticsearch
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	//+optional
+	Error string `json:"error,omitempty"`
+	// Result of every step of synchronization: tenants, role, role mapping and release of previous name after rename
+	//+optional
+	Steps []RoleStepStatus `json:"steps,omitempty"`
+	// Conflict condition is true, when elasticsearch object or its name belongs to another resource or object wasn't created by operator
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// RoleStepStatus defines result of step of role synchronization
+type RoleStepStatus struct {
+	//+kubebuilder:validation:Enum=Tenants;Role;RoleMapping;Rename
+	Name string `json:"name"`
+	// Deployed, Error or Skipped, when previous step failed
+	State string `json:"state"`
 	//+optional
 	Error string `json:"error,omitempty"`
 }
@@ -66,6 +102,7 @@ type RoleStatus struct {
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Elasticsearch name",type=string,JSONPath=`.status.name`
 //+kubebuilder:printcolumn:name="Role mappings",type=string,JSONPath=`.spec.roleMappings.backend_roles`
 
 // Role is the Schema for the roles API
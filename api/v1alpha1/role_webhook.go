@@ -0,0 +1,148 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var rolelog = logf.Log.WithName("role-resource")
+
+// UnknownActionsFunc returns allowed actions, unknown to the cluster.
+// If set, webhook rejects roles with unknown action groups or permissions
+var UnknownActionsFunc func(actions []string) ([]string, error)
+
+// DefaultClusterPermissions are set on creation of roles without cluster permissions, e.g. read-only composite
+// operations, which Kibana users can't work without
+var DefaultClusterPermissions []string
+
+// SetupWebhookWithManager register Role webhooks in manager
+func (r *Role) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-security-rshbdev-ru-v1alpha1-role,mutating=true,failurePolicy=fail,sideEffects=None,groups=security.rshbdev.ru,resources=roles,verbs=create;update,versions=v1alpha1,name=mrole.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Defaulter = &Role{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Role) Default() {
+	rolelog.Info("default", "name", r.Name)
+	if r.Spec.Description == "" {
+		r.Spec.Description = r.Namespace + "/" + r.Name
+	}
+	// Object has no creation timestamp until it is created, so permissions removed later aren't added again
+	if r.Spec.ClusterPermissons == nil && len(DefaultClusterPermissions) > 0 && r.CreationTimestamp.IsZero() {
+		r.Spec.ClusterPermissons = append([]string(nil), DefaultClusterPermissions...)
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-security-rshbdev-ru-v1alpha1-role,mutating=false,failurePolicy=fail,sideEffects=None,groups=security.rshbdev.ru,resources=roles,verbs=create;update,versions=v1alpha1,name=vrole.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &Role{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Role) ValidateCreate() error {
+	rolelog.Info("validate create", "name", r.Name)
+	return r.validateRole()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Role) ValidateUpdate(old runtime.Object) error {
+	rolelog.Info("validate update", "name", r.Name)
+	// Deletion, finalizer removal and metadata updates must not be blocked by validation
+	oldRole, ok := old.(*Role)
+	if r.DeletionTimestamp != nil || ok && equality.Semantic.DeepEqual(oldRole.Spec, r.Spec) {
+		return nil
+	}
+	return r.validateRole()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Role) ValidateDelete() error {
+	return nil
+}
+
+func (r *Role) validateRole() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	allErrs = append(allErrs, validateAllowedActions(specPath.Child("cluster_permissions"), r.Spec.ClusterPermissons)...)
+	for i, indexPermission := range r.Spec.IndexPermissions {
+		path := specPath.Child("index_permissions").Index(i)
+		allErrs = append(allErrs, validateNotEmptyStrings(path.Child("index_patterns"), indexPermission.IndexPatterns)...)
+		allErrs = append(allErrs, validateTemplates(path.Child("index_patterns"), indexPermission.IndexPatterns)...)
+		allErrs = append(allErrs, validateNotEmptyStrings(path.Child("allowed_actions"), indexPermission.AllowedActions)...)
+		allErrs = append(allErrs, validateAllowedActions(path.Child("allowed_actions"), indexPermission.AllowedActions)...)
+		if indexPermission.DLS != "" {
+			if err := validateJSON(path.Child("dls"), indexPermission.DLS); err != nil {
+				allErrs = append(allErrs, err)
+			}
+			if err := validateTemplate(path.Child("dls"), indexPermission.DLS); err != nil {
+				allErrs = append(allErrs, err)
+			}
+		}
+		for j, fls := range indexPermission.FLS {
+			if fls == "" || fls == "~" {
+				allErrs = append(allErrs, field.Invalid(path.Child("fls").Index(j), fls, "must be field name or ~field name"))
+			}
+		}
+	}
+	for i, tenantPermission := range r.Spec.TenantPermissions {
+		path := specPath.Child("tenant_permissions").Index(i)
+		allErrs = append(allErrs, validateNotEmptyStrings(path.Child("tenant_patterns"), tenantPermission.TenantPatterns)...)
+		allErrs = append(allErrs, validateTemplates(path.Child("tenant_patterns"), tenantPermission.TenantPatterns)...)
+		allErrs = append(allErrs, validateNotEmptyStrings(path.Child("allowed_actions"), tenantPermission.AllowedActions)...)
+		allErrs = append(allErrs, validateAllowedActions(path.Child("allowed_actions"), tenantPermission.AllowedActions)...)
+	}
+	if UnknownActionsFunc != nil {
+		allErrs = append(allErrs, validateKnownActions(specPath.Child("cluster_permissions"), r.Spec.ClusterPermissons)...)
+		for i, indexPermission := range r.Spec.IndexPermissions {
+			allErrs = append(allErrs, validateKnownActions(specPath.Child("index_permissions").Index(i).Child("allowed_actions"), indexPermission.AllowedActions)...)
+		}
+		for i, tenantPermission := range r.Spec.TenantPermissions {
+			allErrs = append(allErrs, validateKnownActions(specPath.Child("tenant_permissions").Index(i).Child("allowed_actions"), tenantPermission.AllowedActions)...)
+		}
+	}
+	return invalidError("Role", r.Name, allErrs)
+}
+
+// validateKnownActions check actions against the cluster. Roles are not rejected, if known actions are not fetched yet
+func validateKnownActions(path *field.Path, actions []string) field.ErrorList {
+	var allErrs field.ErrorList
+	unknown, err := UnknownActionsFunc(actions)
+	if err != nil {
+		rolelog.Error(err, "unable to validate allowed actions against the cluster")
+		return nil
+	}
+	for i, action := range actions {
+		for _, unknownAction := range unknown {
+			if action == unknownAction {
+				allErrs = append(allErrs, field.Invalid(path.Index(i), action, "unknown action group or permission"))
+				break
+			}
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RoleTemplateSpec defines the desired state of RoleTemplate
+type RoleTemplateSpec struct {
+	// Role is created in every namespace, matching selector
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+	// Role spec, may use the same templates as Role
+	Role RoleSpec `json:"role"`
+}
+
+// RoleTemplateStatus defines the observed state of RoleTemplate
+type RoleTemplateStatus struct {
+	//+optional
+	Status string `json:"state,omitempty"`
+	//+optional
+	Error string `json:"error,omitempty"`
+	//+optional
+	Roles []StatusTemplateRole `json:"roles,omitempty"`
+	// Generation of resource, which was successfully synchronized with elasticsearch
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conflict condition is true, when any role of template belongs to another resource or wasn't created by operator
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// StatusTemplateRole defines role, created from template for namespace
+type StatusTemplateRole struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Tenants are kept to delete them, when namespace labels are not available anymore
+	//+optional
+	Tenants []string `json:"tenants,omitempty"`
+	Status  string   `json:"state"`
+	//+optional
+	Error string `json:"error,omitempty"`
+	// Result of every step of the last synchronization of role
+	//+optional
+	Steps []RoleStepStatus `json:"steps,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.state`
+
+// RoleTemplate is the Schema for the roletemplates API
+type RoleTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RoleTemplateSpec   `json:"spec,omitempty"`
+	Status RoleTemplateStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RoleTemplateList contains a list of RoleTemplate
+type RoleTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RoleTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RoleTemplate{}, &RoleTemplateList{})
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Operations of SecurityChangeLog
+const (
+	ChangeOperationCreate = "Create"
+	ChangeOperationUpdate = "Update"
+	ChangeOperationDelete = "Delete"
+)
+
+// SecurityChangeLogSpec defines change of elasticsearch object, made by operator
+type SecurityChangeLogSpec struct {
+	// Time of change
+	Timestamp metav1.Time `json:"timestamp"`
+	//+kubebuilder:validation:Enum=Create;Update;Delete
+	Operation string `json:"operation"`
+	// Kind of elasticsearch object: role, rolemapping, user, tenant, actiongroup or monitor
+	Kind string `json:"kind"`
+	// Name of elasticsearch object, ID for monitors
+	Name string `json:"name"`
+	// HTTP method of request to Elasticsearch API
+	Method string `json:"method"`
+	// Path of request to Elasticsearch API
+	Path string `json:"path"`
+	// Changed fields of elasticsearch object. Secrets are masked
+	//+optional
+	Changes []SecurityChange `json:"changes,omitempty"`
+	// Changes are truncated, because there are too many of them
+	//+optional
+	ChangesTruncated bool `json:"changesTruncated,omitempty"`
+	// Resource, which reconcile made the change
+	Source SecurityChangeSource `json:"source"`
+}
+
+// SecurityChange defines change of single field
+type SecurityChange struct {
+	// Path of field, like `index_permissions[0].allowed_actions[1]`
+	Path string `json:"path"`
+	// JSON value before change, empty if field was added
+	//+optional
+	Before string `json:"before,omitempty"`
+	// JSON value after change, empty if field was removed
+	//+optional
+	After string `json:"after,omitempty"`
+}
+
+// SecurityChangeSource defines custom resource, which caused change
+type SecurityChangeSource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	//+optional
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	UID       types.UID `json:"uid"`
+	//+optional
+	Generation int64 `json:"generation,omitempty"`
+	// Field manager, which made the latest change of resource spec, from managedFields.
+	// Kubernetes doesn't store user name in resource, it can be found in API server audit log by time of change
+	//+optional
+	Manager string `json:"manager,omitempty"`
+	// Time of the latest change of resource spec
+	//+optional
+	ManagerTime *metav1.Time `json:"managerTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.kind`
+//+kubebuilder:printcolumn:name="Name",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Operation",type=string,JSONPath=`.spec.operation`
+//+kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.source.name`
+//+kubebuilder:printcolumn:name="Manager",type=string,JSONPath=`.spec.source.manager`
+//+kubebuilder:printcolumn:name="Timestamp",type=date,JSONPath=`.spec.timestamp`
+
+// SecurityChangeLog is the Schema for the securitychangelogs API. Records are append-only audit trail of changes,
+// made by operator in Elasticsearch, and are deleted after retention period
+type SecurityChangeLog struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SecurityChangeLogSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SecurityChangeLogList contains a list of SecurityChangeLog
+type SecurityChangeLogList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SecurityChangeLog `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SecurityChangeLog{}, &SecurityChangeLogList{})
+}
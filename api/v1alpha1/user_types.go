@@ -25,18 +25,41 @@ import (
 
 // UserSpec defines the desired state of User
 type UserSpec struct {
+	// Explicit name of elasticsearch user, overrides operator naming strategy
+	//+optional
+	Name         string `json:"name,omitempty"`
 	PasswordHash string `json:"hash"`
+	// Take over existing elasticsearch user, which is not managed by this resource
+	//+optional
+	Adopt bool `json:"adopt,omitempty"`
+	// What to do with elasticsearch objects, when resource is deleted. Operator default is used, if not set
+	//+kubebuilder:validation:Enum=Delete;RetainTenants;Retain;Orphan
+	//+optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
 }
 
 // UserStatus defines the observed state of User
 type UserStatus struct {
 	Status string `json:"state"`
+	// Name of elasticsearch user, managed by this resource
+	//+optional
+	Name string `json:"name,omitempty"`
+	// Generation of resource, which was successfully synchronized with elasticsearch
+	//+optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 	//+optional
 	Error string `json:"error,omitempty"`
+	// Conflict condition is true, when elasticsearch object or its name belongs to another resource or object wasn't created by operator
+	//+optional
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Elasticsearch name",type=string,JSONPath=`.status.name`
 
 // User is the Schema for the users API
 type User struct {
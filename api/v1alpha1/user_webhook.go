@@ -0,0 +1,71 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var userlog = logf.Log.WithName("user-resource")
+
+// SetupWebhookWithManager register User webhooks in manager
+func (r *User) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-security-rshbdev-ru-v1alpha1-user,mutating=false,failurePolicy=fail,sideEffects=None,groups=security.rshbdev.ru,resources=users,verbs=create;update,versions=v1alpha1,name=vuser.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &User{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *User) ValidateCreate() error {
+	userlog.Info("validate create", "name", r.Name)
+	return r.validateUser()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *User) ValidateUpdate(old runtime.Object) error {
+	userlog.Info("validate update", "name", r.Name)
+	// Deletion, finalizer removal and metadata updates must not be blocked by validation
+	oldUser, ok := old.(*User)
+	if r.DeletionTimestamp != nil || ok && equality.Semantic.DeepEqual(oldUser.Spec, r.Spec) {
+		return nil
+	}
+	return r.validateUser()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *User) ValidateDelete() error {
+	return nil
+}
+
+func (r *User) validateUser() error {
+	var allErrs field.ErrorList
+	if !bcryptHashRegexp.MatchString(r.Spec.PasswordHash) {
+		// Don't show hash in error message
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "hash"), "<hash>", "must be bcrypt password hash"))
+	}
+	return invalidError("User", r.Name, allErrs)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"text/template"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	// Action group name (e.g. "read") or permission (e.g. "indices:data/read/*")
+	allowedActionRegexp = regexp.MustCompile(`^([a-zA-Z0-9_\-*]+|(cluster|indices|kibana):[a-zA-Z0-9_\-/*:.\[\]]+)$`)
+	// Password hash, generated with bcrypt
+	bcryptHashRegexp = regexp.MustCompile(`^\$2[abxy]?\$\d{2}\$[./A-Za-z0-9]{53}$`)
+)
+
+// validateAllowedActions check that every action is a valid action group name or permission
+func validateAllowedActions(path *field.Path, actions []string) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, action := range actions {
+		if !allowedActionRegexp.MatchString(action) {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), action, "must be action group name or permission like indices:data/read/*"))
+		}
+	}
+	return allErrs
+}
+
+// validateNotEmptyStrings check that list has at least one element and there are no empty ones
+func validateNotEmptyStrings(path *field.Path, values []string) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(values) == 0 {
+		return append(allErrs, field.Required(path, "at least one value is required"))
+	}
+	for i, value := range values {
+		if strings.TrimSpace(value) == "" {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), value, "must not be empty"))
+		}
+	}
+	return allErrs
+}
+
+// validateJSON check that passed string is valid json document
+func validateJSON(path *field.Path, value string) *field.Error {
+	if !json.Valid([]byte(value)) {
+		return field.Invalid(path, value, "must be valid JSON")
+	}
+	return nil
+}
+
+// validateTemplate check that passed string is valid go template
+func validateTemplate(path *field.Path, value string) *field.Error {
+	if _, err := template.New(path.String()).Parse(value); err != nil {
+		return field.Invalid(path, value, "must be valid template: "+err.Error())
+	}
+	return nil
+}
+
+// validateTemplates check that every passed string is valid go template
+func validateTemplates(path *field.Path, values []string) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, value := range values {
+		if err := validateTemplate(path.Index(i), value); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
+// invalidError wrap field errors to API error, returned by webhook
+func invalidError(kind, name string, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind(kind).GroupKind(), name, allErrs)
+}
@@ -2,13 +2,17 @@ package config
 
 import (
 	"crypto/x509"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-
-	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/aberestyak/elasticsearch-security-operator/internal/logger"
 )
 
 // Config structure with operator's config
@@ -19,10 +23,30 @@ type Config struct {
 	ElasticsearchRoleAPIPath        string         `mapstructure:"roleAPIPath"`
 	ElasticsearchUserAPIPath        string         `mapstructure:"userAPIPath"`
 	ElasticsearchRoleMappingAPIPath string         `mapstructure:"roleMappingAPIPath"`
+	ElasticsearchActionGroupAPIPath string         `mapstructure:"actionGroupAPIPath"`
+	ElasticsearchAuthInfoAPIPath    string         `mapstructure:"authInfoAPIPath"`
 	ElasticsearchUsername           string         `mapstructure:"username"`
 	ExtraCACertFile                 string         `mapstructure:"extraCACertFile"`
 	ExtraCACert                     *x509.CertPool `mapstructure:"extraCACert"`
 	ElasticsearchPassword           string         `mapstructure:"password"`
+	AlertStateRefreshInterval       time.Duration  `mapstructure:"alertStateRefreshInterval"`
+	RejectUnknownActions            bool           `mapstructure:"rejectUnknownActions"`
+	DefaultClusterPermissions       []string       `mapstructure:"defaultClusterPermissions"`
+	KnownPermissions                []string       `mapstructure:"knownPermissions"`
+	NamingStrategy                  string         `mapstructure:"namingStrategy"`
+	ProtectedObjectsAllowlist       []string       `mapstructure:"protectedObjectsAllowlist"`
+	DefaultDeletionPolicy           string         `mapstructure:"defaultDeletionPolicy"`
+	ReadinessCacheTTL               time.Duration  `mapstructure:"readinessCacheTTL"`
+	TracingEnabled                  bool           `mapstructure:"tracingEnabled"`
+	TracingEndpoint                 string         `mapstructure:"tracingEndpoint"`
+	TracingServiceName              string         `mapstructure:"tracingServiceName"`
+	TracingSampleRatio              float64        `mapstructure:"tracingSampleRatio"`
+	ChangeLogEnabled                bool           `mapstructure:"changeLogEnabled"`
+	ChangeLogRetention              time.Duration  `mapstructure:"changeLogRetention"`
+	BulkRequestsEnabled             bool           `mapstructure:"bulkRequestsEnabled"`
+	BulkRequestsWindow              time.Duration  `mapstructure:"bulkRequestsWindow"`
+	BulkRequestsMaxSize             int            `mapstructure:"bulkRequestsMaxSize"`
+	MaxConcurrentReconciles         int            `mapstructure:"maxConcurrentReconciles"`
 }
 
 const (
@@ -33,17 +57,69 @@ const (
 	elasticsearchTenantAPIPath      = "ELASTICSEARCH_TENANT_API_PATH"
 	elasticsearchUserAPIPath        = "ELASTICSEARCH_USER_API_PATH"
 	elasticsearchRoleMappingAPIPath = "ELASTICSEARCH_ROLEMAPPING_API_PATH"
+	elasticsearchActionGroupAPIPath = "ELASTICSEARCH_ACTIONGROUP_API_PATH"
+	elasticsearchAuthInfoAPIPath    = "ELASTICSEARCH_AUTHINFO_API_PATH"
 	extraCACertFile                 = "EXTRA_CA_CERT_FILE"
 	elasticsearchUsername           = "ELASTICSEARCH_USERNAME"
 	elasticsearchPassword           = "ELASTICSEARCH_PASSWORD"
+	alertStateRefreshInterval       = "ALERT_STATE_REFRESH_INTERVAL"
+	rejectUnknownActions            = "REJECT_UNKNOWN_ACTIONS"
+	defaultClusterPermissions       = "DEFAULT_CLUSTER_PERMISSIONS"
+	knownPermissions                = "KNOWN_PERMISSIONS"
+	namingStrategy                  = "NAMING_STRATEGY"
+	protectedObjectsAllowlist       = "PROTECTED_OBJECTS_ALLOWLIST"
+	defaultDeletionPolicy           = "DEFAULT_DELETION_POLICY"
+	readinessCacheTTL               = "READINESS_CACHE_TTL"
+	tracingEnabled                  = "TRACING_ENABLED"
+	tracingEndpoint                 = "TRACING_ENDPOINT"
+	tracingServiceName              = "TRACING_SERVICE_NAME"
+	tracingSampleRatio              = "TRACING_SAMPLE_RATIO"
+	changeLogEnabled                = "CHANGE_LOG_ENABLED"
+	changeLogRetention              = "CHANGE_LOG_RETENTION"
+	bulkRequestsEnabled             = "BULK_REQUESTS_ENABLED"
+	bulkRequestsWindow              = "BULK_REQUESTS_WINDOW"
+	bulkRequestsMaxSize             = "BULK_REQUESTS_MAX_SIZE"
+	maxConcurrentReconciles         = "MAX_CONCURRENT_RECONCILES"
+)
+
+const (
+	defaultAlertStateRefreshInterval = time.Minute
+	defaultAuthInfoAPIPath           = "_opendistro/_security/authinfo"
+	defaultReadinessCacheTTL         = 30 * time.Second
+	defaultTracingEndpoint           = "http://localhost:4318"
+	defaultTracingServiceName        = "elasticsearch-security-operator"
+	defaultTracingSampleRatio        = 1.0
+	defaultChangeLogRetention        = 30 * 24 * time.Hour
+	defaultBulkRequestsWindow        = 100 * time.Millisecond
+	defaultBulkRequestsMaxSize       = 100
+	// Reconciles must run concurrently to be batched
+	defaultBulkConcurrentReconciles = 10
+)
+
+// Naming strategies of elasticsearch objects, created for namespaced custom resources
+const (
+	// NamingStrategyPlain - elasticsearch object is named after custom resource
+	NamingStrategyPlain = "plain"
+	// NamingStrategyNamespaceName - elasticsearch object is named <namespace>-<name>
+	NamingStrategyNamespaceName = "namespace-name"
+)
+
+// Deletion policies of elasticsearch objects, applied when custom resource is deleted
+const (
+	// DeletionPolicyDelete - delete elasticsearch objects, including tenants
+	DeletionPolicyDelete = "Delete"
+	// DeletionPolicyRetainTenants - delete elasticsearch objects, but retain tenants with Kibana saved objects
+	DeletionPolicyRetainTenants = "RetainTenants"
+	// DeletionPolicyRetain - keep elasticsearch objects, like Retain reclaim policy of Kubernetes volumes
+	DeletionPolicyRetain = "Retain"
+	// DeletionPolicyOrphan - don't touch elasticsearch objects
+	DeletionPolicyOrphan = "Orphan"
 )
 
 var (
 	// AppConfig object with applied config
 	AppConfig    = loadConfig()
-	configLogger = log.WithFields(log.Fields{
-		"component": "ConfigInit",
-	})
+	configLogger = logger.New().WithName("ConfigInit")
 )
 
 func loadConfig() *Config {
@@ -51,13 +127,25 @@ func loadConfig() *Config {
 	var conf Config
 
 	if _, err := os.Stat(devConfigFile); os.IsNotExist(err) {
-		log.Println("Load configuration from environment variables")
+		configLogger.Info("Load configuration from environment variables")
 		viper.SetDefault(elasticsearchAlertAPIPath, "_opendistro/_alerting/monitors")
 		viper.SetDefault(elasticsearchRoleAPIPath, "_opendistro/_security/api/roles")
 		viper.SetDefault(elasticsearchUserAPIPath, "_opendistro/_security/api/internalusers")
 		viper.SetDefault(conf.ElasticsearchTenantAPIPath, "_opendistro/_security/api/tenants")
 		viper.SetDefault(elasticsearchRoleMappingAPIPath, "_opendistro/_security/api/rolesmapping")
+		viper.SetDefault(elasticsearchActionGroupAPIPath, "_opendistro/_security/api/actiongroups")
+		viper.SetDefault(elasticsearchAuthInfoAPIPath, defaultAuthInfoAPIPath)
 		viper.SetDefault(extraCACertFile, "")
+		viper.SetDefault(alertStateRefreshInterval, defaultAlertStateRefreshInterval)
+		viper.SetDefault(namingStrategy, NamingStrategyPlain)
+		viper.SetDefault(defaultDeletionPolicy, DeletionPolicyDelete)
+		viper.SetDefault(readinessCacheTTL, defaultReadinessCacheTTL)
+		viper.SetDefault(tracingEndpoint, defaultTracingEndpoint)
+		viper.SetDefault(tracingServiceName, defaultTracingServiceName)
+		viper.SetDefault(tracingSampleRatio, defaultTracingSampleRatio)
+		viper.SetDefault(changeLogRetention, defaultChangeLogRetention)
+		viper.SetDefault(bulkRequestsWindow, defaultBulkRequestsWindow)
+		viper.SetDefault(bulkRequestsMaxSize, defaultBulkRequestsMaxSize)
 
 		conf.ElasticsearchEndpoint = viper.GetString(elasticsearchEndpoint)
 		conf.ElasticsearchAlertAPIPath = viper.GetString(elasticsearchAlertAPIPath)
@@ -65,35 +153,125 @@ func loadConfig() *Config {
 		conf.ElasticsearchUserAPIPath = viper.GetString(elasticsearchUserAPIPath)
 		conf.ElasticsearchTenantAPIPath = viper.GetString(elasticsearchTenantAPIPath)
 		conf.ElasticsearchRoleMappingAPIPath = viper.GetString(elasticsearchRoleMappingAPIPath)
+		conf.ElasticsearchActionGroupAPIPath = viper.GetString(elasticsearchActionGroupAPIPath)
+		conf.ElasticsearchAuthInfoAPIPath = viper.GetString(elasticsearchAuthInfoAPIPath)
 		conf.ExtraCACertFile = viper.GetString(extraCACertFile)
 		conf.ElasticsearchUsername = viper.GetString(elasticsearchUsername)
 		conf.ElasticsearchPassword = viper.GetString(elasticsearchPassword)
+		conf.AlertStateRefreshInterval = viper.GetDuration(alertStateRefreshInterval)
+		conf.RejectUnknownActions = viper.GetBool(rejectUnknownActions)
+		conf.DefaultClusterPermissions = splitList(viper.GetString(defaultClusterPermissions))
+		conf.KnownPermissions = splitList(viper.GetString(knownPermissions))
+		conf.NamingStrategy = viper.GetString(namingStrategy)
+		conf.ProtectedObjectsAllowlist = splitList(viper.GetString(protectedObjectsAllowlist))
+		conf.DefaultDeletionPolicy = viper.GetString(defaultDeletionPolicy)
+		conf.ReadinessCacheTTL = viper.GetDuration(readinessCacheTTL)
+		conf.TracingEnabled = viper.GetBool(tracingEnabled)
+		conf.TracingEndpoint = viper.GetString(tracingEndpoint)
+		conf.TracingServiceName = viper.GetString(tracingServiceName)
+		conf.TracingSampleRatio = viper.GetFloat64(tracingSampleRatio)
+		conf.ChangeLogEnabled = viper.GetBool(changeLogEnabled)
+		conf.ChangeLogRetention = viper.GetDuration(changeLogRetention)
+		conf.BulkRequestsEnabled = viper.GetBool(bulkRequestsEnabled)
+		conf.BulkRequestsWindow = viper.GetDuration(bulkRequestsWindow)
+		conf.BulkRequestsMaxSize = viper.GetInt(bulkRequestsMaxSize)
+		conf.MaxConcurrentReconciles = viper.GetInt(maxConcurrentReconciles)
 
 	} else {
-		configLogger.Println("Load configuration from file:", devConfigFile)
+		configLogger.Info("Load configuration from file", "file", devConfigFile)
 		viper.SetConfigFile(devConfigFile)
+		// Zero is valid ratio and retention, so defaults can't be applied after decoding
+		viper.SetDefault("tracingSampleRatio", defaultTracingSampleRatio)
+		viper.SetDefault("changeLogRetention", defaultChangeLogRetention)
 		if err := viper.ReadInConfig(); err != nil {
-			configLogger.Fatalf("Fatal error config file %v: %s \n", devConfigFile, err)
+			fatal(err, "Unable to read config file", "file", devConfigFile)
 		}
 		if err := viper.Unmarshal(&conf); err != nil {
-			configLogger.Fatalf("Unable to decode into struct, %v", err)
+			fatal(err, "Unable to decode into struct")
 		}
 	}
+	if conf.ElasticsearchActionGroupAPIPath == "" {
+		conf.ElasticsearchActionGroupAPIPath = "_opendistro/_security/api/actiongroups"
+	}
+	if conf.ElasticsearchAuthInfoAPIPath == "" {
+		conf.ElasticsearchAuthInfoAPIPath = defaultAuthInfoAPIPath
+	}
+	if conf.ReadinessCacheTTL <= 0 {
+		conf.ReadinessCacheTTL = defaultReadinessCacheTTL
+	}
+	if conf.TracingEndpoint == "" {
+		conf.TracingEndpoint = defaultTracingEndpoint
+	}
+	if conf.TracingServiceName == "" {
+		conf.TracingServiceName = defaultTracingServiceName
+	}
+	if conf.TracingSampleRatio < 0 || conf.TracingSampleRatio > 1 {
+		fatal(errors.New("must be from 0 to 1"), "Wrong tracing sample ratio", "tracingSampleRatio", conf.TracingSampleRatio)
+	}
+	if conf.ChangeLogRetention < 0 {
+		fatal(errors.New("must not be negative"), "Wrong change log retention", "changeLogRetention", conf.ChangeLogRetention.String())
+	}
+	if conf.BulkRequestsWindow <= 0 {
+		conf.BulkRequestsWindow = defaultBulkRequestsWindow
+	}
+	if conf.BulkRequestsMaxSize <= 0 {
+		conf.BulkRequestsMaxSize = defaultBulkRequestsMaxSize
+	}
+	if conf.MaxConcurrentReconciles <= 0 {
+		conf.MaxConcurrentReconciles = 1
+		if conf.BulkRequestsEnabled {
+			conf.MaxConcurrentReconciles = defaultBulkConcurrentReconciles
+		}
+	}
+	if conf.AlertStateRefreshInterval <= 0 {
+		conf.AlertStateRefreshInterval = defaultAlertStateRefreshInterval
+	}
+	switch conf.NamingStrategy {
+	case "":
+		conf.NamingStrategy = NamingStrategyPlain
+	case NamingStrategyPlain, NamingStrategyNamespaceName:
+	default:
+		fatal(fmt.Errorf("must be %v or %v", NamingStrategyPlain, NamingStrategyNamespaceName), "Unknown naming strategy", "namingStrategy", conf.NamingStrategy)
+	}
+	switch conf.DefaultDeletionPolicy {
+	case "":
+		conf.DefaultDeletionPolicy = DeletionPolicyDelete
+	case DeletionPolicyDelete, DeletionPolicyRetainTenants, DeletionPolicyRetain, DeletionPolicyOrphan:
+	default:
+		fatal(fmt.Errorf("must be %v, %v, %v or %v", DeletionPolicyDelete, DeletionPolicyRetainTenants, DeletionPolicyRetain, DeletionPolicyOrphan), "Unknown deletion policy", "defaultDeletionPolicy", conf.DefaultDeletionPolicy)
+	}
 	if conf.ExtraCACertFile != "" {
 		conf.ExtraCACert = appendCACert(conf.ExtraCACertFile)
 	}
 	return &conf
 }
 
+// splitList - split comma separated list from environment variable
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// fatal - log error and exit, config can't be loaded
+func fatal(err error, msg string, keysAndValues ...interface{}) {
+	configLogger.Error(err, msg, keysAndValues...)
+	os.Exit(1)
+}
+
 func appendCACert(file string) *x509.CertPool {
 	caCert, err := ioutil.ReadFile(filepath.Clean(file))
 	if err != nil {
-		configLogger.Fatalf("Unable to read file with custom CA certificates: %v", err)
+		fatal(err, "Unable to read file with custom CA certificates", "file", file)
 	}
 	// Load CA cert
 	caCertPool := x509.NewCertPool()
 	if !caCertPool.AppendCertsFromPEM(caCert) {
-		configLogger.Fatal("Unable to add custom CA certificates to certificates pool")
+		fatal(errors.New("no certificates parsed"), "Unable to add custom CA certificates to certificates pool", "file", file)
 	}
 	return caCertPool
 }
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+	"github.com/aberestyak/elasticsearch-security-operator/config"
+	actiongroups "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/actiongroups"
+	roles "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/roles"
+	"github.com/aberestyak/elasticsearch-security-operator/internal/logger"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// How long fetched action groups are considered actual
+const actionGroupsCacheTTL = 5 * time.Minute
+
+var actionGroupsLogger = ctrl.Log.WithName("ActionGroups")
+
+// knownActionsCache keeps action groups, known to the cluster, and permissions: exact ones from action groups and built-in
+// roles of the cluster, and configured ones
+type knownActionsCache struct {
+	mu          sync.Mutex
+	fetchMu     sync.Mutex
+	fetchedAt   time.Time
+	groups      map[string]bool
+	permissions []string
+	refreshing  bool
+}
+
+var knownActions = &knownActionsCache{}
+
+// get - return cached action groups and permissions, fetching them again if cache is expired
+func (c *knownActionsCache) get(ctx context.Context) (map[string]bool, []string, error) {
+	// Only one fetch at a time, concurrent reconciles wait for its result
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+	if groups, permissions, fresh := c.cached(); fresh {
+		return groups, permissions, nil
+	}
+	existingGroups, err := GetActionGroups(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	builtinRolesPermissions, err := GetBuiltinRolesPermissions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups := make(map[string]bool, len(existingGroups))
+	permissions := append([]string(nil), config.AppConfig.KnownPermissions...)
+	if len(permissions) == 0 {
+		permissions = append(permissions, defaultKnownPermissions...)
+	}
+	permissions = append(permissions, builtinRolesPermissions...)
+	for name, group := range existingGroups {
+		groups[name] = true
+		permissions = append(permissions, exactPermissions(group.AllowedActions)...)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.groups, c.permissions, c.fetchedAt = groups, permissions, time.Now()
+	return groups, permissions, nil
+}
+
+// cached - return cached action groups and permissions and whether they are fresh, without requests to elasticsearch
+func (c *knownActionsCache) cached() (map[string]bool, []string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.groups, c.permissions, c.groups != nil && time.Since(c.fetchedAt) < actionGroupsCacheTTL
+}
+
+// refresh - fetch expired cache in background, once at a time
+func (c *knownActionsCache) refresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refreshing {
+		return
+	}
+	c.refreshing = true
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		if _, _, err := c.get(ctx); err != nil {
+			actionGroupsLogger.Info("Can't fetch action groups and permissions", "error", err.Error())
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.refreshing = false
+	}()
+}
+
+// invalidate - drop cached action groups, so they are fetched on next validation
+func (c *knownActionsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.groups = nil
+}
+
+// GetActionGroups - make GET request to get all action groups
+func GetActionGroups(ctx context.Context) (map[string]actiongroups.ActionGroupAPISpec, error) {
+	_, responseResult, responseBody, err := MakeAPIRequest(ctx, "GET", config.AppConfig.ElasticsearchActionGroupAPIPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if responseResult == "Error" {
+		return nil, errors.New("Error when getting action groups: " + responseText(responseBody))
+	}
+	existingGroups := make(map[string]actiongroups.ActionGroupAPISpec)
+	if err := json.Unmarshal(responseBody, &existingGroups); err != nil {
+		return nil, errors.New("Error when unmarshaling action groups: " + err.Error())
+	}
+	return existingGroups, nil
+}
+
+// GetBuiltinRolesPermissions - make GET request to get all roles and return exact permissions of reserved, static and
+// hidden ones. They are shipped with security plugin, so they are known to the cluster
+func GetBuiltinRolesPermissions(ctx context.Context) ([]string, error) {
+	_, responseResult, responseBody, err := MakeAPIRequest(ctx, "GET", config.AppConfig.ElasticsearchRoleAPIPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if responseResult == "Error" {
+		return nil, errors.New("Error when getting roles: " + responseText(responseBody))
+	}
+	existingRoles := make(map[string]struct {
+		securityObjectFlags
+		roles.RoleAPISpec
+	})
+	if err := json.Unmarshal(responseBody, &existingRoles); err != nil {
+		return nil, errors.New("Error when unmarshaling roles: " + err.Error())
+	}
+	var permissions []string
+	for _, role := range existingRoles {
+		if !role.Reserved && !role.Static && !role.Hidden {
+			continue
+		}
+		permissions = append(permissions, exactPermissions(role.ClusterPermissons)...)
+		for _, indexPermission := range role.IndexPermissions {
+			permissions = append(permissions, exactPermissions(indexPermission.AllowedActions)...)
+		}
+		for _, tenantPermission := range role.TenantPermissions {
+			permissions = append(permissions, exactPermissions(tenantPermission.AllowedActions)...)
+		}
+	}
+	return permissions, nil
+}
+
+// exactPermissions - permissions without wildcards. Wildcards (e.g. indices:*) aren't known permissions, because they match any typo
+func exactPermissions(actions []string) []string {
+	var permissions []string
+	for _, action := range actions {
+		if isPermission(action) && !isWildcard(action) {
+			permissions = append(permissions, action)
+		}
+	}
+	return permissions
+}
+
+// UnknownActions - return actions, which are neither known action groups nor permissions. Used by webhook, which has no
+// request context, so it checks only cached actions and never waits for elasticsearch. Expired cache is refreshed in background
+func UnknownActions(actions []string) ([]string, error) {
+	groups, permissions, fresh := knownActions.cached()
+	if !fresh {
+		knownActions.refresh()
+	}
+	if groups == nil {
+		return nil, errors.New("action groups and permissions are not fetched yet")
+	}
+	return filterUnknownActions(groups, permissions, actions), nil
+}
+
+func unknownActions(ctx context.Context, actions []string) ([]string, error) {
+	groups, permissions, err := knownActions.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterUnknownActions(groups, permissions, actions), nil
+}
+
+func filterUnknownActions(groups map[string]bool, permissions []string, actions []string) []string {
+	var unknown []string
+	for _, action := range actions {
+		if isPermission(action) {
+			if !isKnownPermission(action, permissions) {
+				unknown = append(unknown, action)
+			}
+		} else if !groups[action] {
+			unknown = append(unknown, action)
+		}
+	}
+	return unknown
+}
+
+// ValidateRoleActions - check every allowed action of role and return description of unknown ones
+func ValidateRoleActions(ctx context.Context, role *v1alpha1.Role) (string, error) {
+	var invalid []string
+	check := func(path string, actions []string) error {
+		unknown, err := unknownActions(ctx, actions)
+		if err != nil {
+			return err
+		}
+		if len(unknown) > 0 {
+			invalid = append(invalid, fmt.Sprintf("%v: %v", path, strings.Join(unknown, ", ")))
+		}
+		return nil
+	}
+	if err := check("spec.cluster_permissions", role.Spec.ClusterPermissons); err != nil {
+		return "", err
+	}
+	for i, indexPermission := range role.Spec.IndexPermissions {
+		if err := check(fmt.Sprintf("spec.index_permissions[%d].allowed_actions", i), indexPermission.AllowedActions); err != nil {
+			return "", err
+		}
+	}
+	for i, tenantPermission := range role.Spec.TenantPermissions {
+		if err := check(fmt.Sprintf("spec.tenant_permissions[%d].allowed_actions", i), tenantPermission.AllowedActions); err != nil {
+			return "", err
+		}
+	}
+	if len(invalid) == 0 {
+		return "", nil
+	}
+	return "Unknown action groups or permissions: " + strings.Join(invalid, "; "), nil
+}
+
+func isPermission(action string) bool {
+	return strings.Contains(action, ":")
+}
+
+func isWildcard(action string) bool {
+	return strings.ContainsAny(action, "*?")
+}
+
+// isKnownPermission - permission is known, if it is one of known permissions, and wildcard is known, if it matches any of them
+func isKnownPermission(permission string, knownPermissions []string) bool {
+	for _, knownPermission := range knownPermissions {
+		if wildcardMatch(permission, knownPermission) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardMatch - match value against pattern with * and ? wildcards, the same way as security plugin does
+func wildcardMatch(pattern, value string) bool {
+	if !isWildcard(pattern) {
+		return pattern == value
+	}
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.ReplaceAll(expression, `\*`, ".*")
+	expression = strings.ReplaceAll(expression, `\?`, ".")
+	matched, err := regexp.MatchString("^"+expression+"$", value)
+	if err != nil {
+		actionGroupsLogger.V(logger.Debug).Info("Can't match permission", "permission", pattern, "error", err.Error())
+		return false
+	}
+	return matched
+}
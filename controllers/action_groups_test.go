@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	config "github.com/aberestyak/elasticsearch-security-operator/config"
+)
+
+func TestIsKnownPermission(t *testing.T) {
+	known := []string{"indices:data/read/search", "indices:data/read/get", "cluster:admin/custom/action"}
+	cases := []struct {
+		permission string
+		known      bool
+	}{
+		{"indices:data/read/search", true},
+		{"indices:data/read/serach", false},
+		{"indices:data/read/*", true},
+		{"indices:data/write/*", false},
+		{"indices:*", true},
+		{"cluster:admin/custom/action", true},
+		{"cluster:admin/custom/*", true},
+	}
+	for _, c := range cases {
+		if got := isKnownPermission(c.permission, known); got != c.known {
+			t.Errorf("isKnownPermission(%q) = %v, want %v", c.permission, got, c.known)
+		}
+	}
+}
+
+func TestGetBuiltinRolesPermissions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"kibana_server": {"reserved": true, "cluster_permissions": ["cluster:monitor/nodes/info", "cluster_composite_ops"],
+				"index_permissions": [{"index_patterns": [".kibana"], "allowed_actions": ["indices:admin/template/*", "indices:admin/refresh"]}]},
+			"alerting_full_access": {"static": true, "cluster_permissions": ["cluster:admin/opendistro/alerting/*"],
+				"tenant_permissions": [{"tenant_patterns": ["*"], "allowed_actions": ["kibana:saved_objects/x/read"]}]},
+			"team-a": {"cluster_permissions": ["cluster:admin/typo"]}
+		}`))
+	}))
+	host, path := defaultRequest.Cfg.Host, config.AppConfig.ElasticsearchRoleAPIPath
+	defaultRequest.Cfg.Host, config.AppConfig.ElasticsearchRoleAPIPath = server.URL, "roles"
+	t.Cleanup(func() {
+		server.Close()
+		defaultRequest.Cfg.Host, config.AppConfig.ElasticsearchRoleAPIPath = host, path
+	})
+
+	permissions, err := GetBuiltinRolesPermissions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(permissions)
+	expected := "cluster:monitor/nodes/info,indices:admin/refresh,kibana:saved_objects/x/read"
+	if strings.Join(permissions, ",") != expected {
+		t.Errorf("expected %v, got %v", expected, permissions)
+	}
+}
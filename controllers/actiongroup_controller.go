@@ -0,0 +1,244 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/go-logr/logr"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+	config "github.com/aberestyak/elasticsearch-security-operator/config"
+	actiongroups "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/actiongroups"
+	"github.com/aberestyak/elasticsearch-security-operator/internal/logger"
+)
+
+const actionGroupFinalizer = "actiongroup.security.rshbdev.ru/finalizer"
+
+// ActionGroupReconciler reconciles a ActionGroup object
+type ActionGroupReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+	ChangeLog *ChangeLog
+}
+
+//+kubebuilder:rbac:groups=security.rshbdev.ru,resources=actiongroups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=security.rshbdev.ru,resources=actiongroups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=security.rshbdev.ru,resources=actiongroups/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile main reconcile loop
+func (r *ActionGroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, log, span := reconcileContext(ctx, r.Log, "ActionGroup", req)
+	defer span.End()
+	desiredActionGroup := &securityv1alpha1.ActionGroup{}
+	var err = r.Get(ctx, req.NamespacedName, desiredActionGroup)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			log.Info("Resource was deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Error while reading CR ActionGroup")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	ctx = r.ChangeLog.WithSource(ctx, desiredActionGroup)
+	// Call finalyzer to clean up
+	if desiredActionGroup.GetDeletionTimestamp() != nil {
+		if controllerutil.ContainsFinalizer(desiredActionGroup, actionGroupFinalizer) {
+			if err := r.FinalizeActionGroup(ctx, desiredActionGroup); err != nil {
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(desiredActionGroup, actionGroupFinalizer)
+			err := r.Update(ctx, desiredActionGroup)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+	// Add finalizer for this CR
+	if !controllerutil.ContainsFinalizer(desiredActionGroup, actionGroupFinalizer) {
+		controllerutil.AddFinalizer(desiredActionGroup, actionGroupFinalizer)
+		err = r.Update(ctx, desiredActionGroup)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Map model to ActionGroupAPISpec
+	actionGroupName := desiredActionGroup.Name
+	actionGroupAPIObject := MapActionGroupAPIObject(desiredActionGroup)
+	// Tag action group, so it is not taken over by another resource or operator
+	actionGroupAPIObject.Description = OwnedDescription(actionGroupAPIObject.Description, desiredActionGroup.UID)
+	apiActionGroupJSON, err := json.Marshal(actionGroupAPIObject)
+	if err != nil {
+		log.Error(err, "Error when marshaling action group object")
+		return ctrl.Result{}, err
+	}
+	actionGroupExists, existingActionGroupSpec, err := GetExistingObject(ctx, config.AppConfig.ElasticsearchActionGroupAPIPath, actionGroupName)
+	if err != nil {
+		log.Error(err, "Error when checking action group existence")
+		return ctrl.Result{}, err
+	}
+	reason := reasonCreated
+	if actionGroupExists {
+		existingActionGroup := make(map[string]actiongroups.ActionGroupAPISpec, 1)
+		if err := json.Unmarshal(existingActionGroupSpec, &existingActionGroup); err != nil {
+			log.Error(err, "Error when unmarshaling existing action group")
+			return ctrl.Result{}, err
+		}
+		// Refuse to modify built-in action group
+		protection, err := CheckProtected("actiongroup", actionGroupName, existingActionGroupSpec)
+		if err != nil {
+			log.Error(err, "Error when checking action group protection")
+			return ctrl.Result{}, err
+		}
+		if protection != "" {
+			log.Info("ActionGroup targets protected action group", "reason", protection)
+			recordWarning(r.Recorder, desiredActionGroup, reasonProtected, protection)
+			if err := SetActionGroupStatus(ctx, r, desiredActionGroup, "Protected", []byte(protection)); err != nil {
+				log.Error(err, "Error when setting action group status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+		// Refuse to overwrite action group, created by hand or by another operator
+		owner := DescriptionOwner(existingActionGroup[actionGroupName].Description)
+		if conflict := CheckOwnership("action group", actionGroupName, owner, desiredActionGroup.UID, desiredActionGroup.Spec.Adopt, desiredActionGroup.Status.Status == "Deployed"); conflict != "" {
+			log.Info("ActionGroup conflicts with existing action group", "reason", conflict)
+			recordWarning(r.Recorder, desiredActionGroup, reasonConflict, conflict)
+			if err := SetActionGroupStatus(ctx, r, desiredActionGroup, "Conflict", []byte(conflict)); err != nil {
+				log.Error(err, "Error when setting action group status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+		// Nothing to update
+		if existingActionGroup[actionGroupName].Equal(*actionGroupAPIObject) && desiredActionGroup.Status.Status == "Deployed" {
+			log.V(logger.Debug).Info("ActionGroup is up to date")
+			RecordSuccessfulSync("actiongroup", desiredActionGroup)
+			return ctrl.Result{}, nil
+		}
+		reason = updateReason(desiredActionGroup.Generation, desiredActionGroup.Status.ObservedGeneration)
+		if reason == reasonDriftCorrected {
+			RecordDriftCorrection("actiongroup")
+		}
+	}
+	if err := CreateOrUpdateActionGroup(ctx, r, desiredActionGroup, apiActionGroupJSON, reason); err != nil {
+		log.Error(err, "Error when updating action group")
+		recordWarning(r.Recorder, desiredActionGroup, reasonFailed, err.Error())
+		return ctrl.Result{}, err
+	}
+	if desiredActionGroup.Status.Status == "Deployed" {
+		RecordSuccessfulSync("actiongroup", desiredActionGroup)
+	}
+	return ctrl.Result{}, nil
+}
+
+// MapActionGroupAPIObject - map CRD model to API
+func MapActionGroupAPIObject(actionGroup *securityv1alpha1.ActionGroup) *actiongroups.ActionGroupAPISpec {
+	return &actiongroups.ActionGroupAPISpec{
+		AllowedActions: actionGroup.Spec.AllowedActions,
+		Type:           actionGroup.Spec.Type,
+		Description:    actionGroup.Spec.Description,
+	}
+}
+
+// CreateOrUpdateActionGroup - make PUT request to create or update ActionGroup and emit event with passed reason
+func CreateOrUpdateActionGroup(ctx context.Context, r *ActionGroupReconciler, actionGroup *securityv1alpha1.ActionGroup, jsonActionGroup []byte, reason string) error {
+	_, responseResult, responseBody, err := MakeAPIRequest(ctx, "PUT", config.AppConfig.ElasticsearchActionGroupAPIPath+"/"+actionGroup.Name, jsonActionGroup)
+	if err != nil {
+		return errors.New("Error when creating new action group: " + err.Error())
+	}
+	recordAPIResult(r.Recorder, actionGroup, responseResult, reason, reason+" elasticsearch action group "+actionGroup.Name, responseBody)
+	// Roles, referencing this group, must be validated against new groups
+	knownActions.invalidate()
+	if err := SetActionGroupStatus(ctx, r, actionGroup, responseResult, responseBody); err != nil {
+		return err
+	}
+	ctrl.LoggerFrom(ctx).Info("Updated action group", "state", actionGroup.Status.Status)
+	return nil
+}
+
+// SetActionGroupStatus - parse http response code, set status and update CR
+func SetActionGroupStatus(ctx context.Context, r *ActionGroupReconciler, actionGroup *securityv1alpha1.ActionGroup, responseResult string, responseBody []byte) error {
+	observedGeneration := actionGroup.Status.ObservedGeneration
+	if responseResult == "Deployed" {
+		observedGeneration = actionGroup.Generation
+	}
+	actionGroup.Status = securityv1alpha1.ActionGroupStatus{
+		Status:             responseResult,
+		ObservedGeneration: observedGeneration,
+		Conditions:         actionGroup.Status.Conditions,
+		Error: func(response string, responseBody []byte) string {
+			if response != "Deployed" {
+				return responseText(responseBody)
+			}
+			return ""
+		}(responseResult, responseBody),
+	}
+	setConflictCondition(&actionGroup.Status.Conditions, actionGroup.Generation, responseResult, actionGroup.Status.Error)
+	if err := r.Client.Status().Update(ctx, actionGroup); err != nil {
+		return errors.New("Error when setting status: " + err.Error())
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ActionGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&securityv1alpha1.ActionGroup{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: config.AppConfig.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// FinalizeActionGroup delete action group
+func (r *ActionGroupReconciler) FinalizeActionGroup(ctx context.Context, actionGroup *securityv1alpha1.ActionGroup) error {
+	log := ctrl.LoggerFrom(ctx)
+	// Elasticsearch action group belongs to another resource or is built-in
+	if actionGroup.Status.Status == "Conflict" || actionGroup.Status.Status == "Protected" {
+		log.Info("ActionGroup doesn't manage elasticsearch action group, skip deletion")
+		return nil
+	}
+	if deletionPolicy := EffectiveDeletionPolicy(actionGroup.Spec.DeletionPolicy); KeepsObjects(deletionPolicy) {
+		log.Info("Keep elasticsearch action group", "deletionPolicy", deletionPolicy)
+		recordNormal(r.Recorder, actionGroup, reasonOrphaned, "Kept elasticsearch action group with %v deletion policy", deletionPolicy)
+		ForgetSync("actiongroup", actionGroup)
+		return nil
+	}
+	if err := DeleteUnprotectedObject(ctx, "actiongroup", config.AppConfig.ElasticsearchActionGroupAPIPath, actionGroup.Name); err != nil {
+		log.Error(err, "Error when finalyzing action group")
+		recordWarning(r.Recorder, actionGroup, reasonFailed, err.Error())
+		return err
+	}
+	knownActions.invalidate()
+	ForgetSync("actiongroup", actionGroup)
+	recordNormal(r.Recorder, actionGroup, reasonDeleted, "Deleted elasticsearch action group %v", actionGroup.Name)
+	log.Info("Successfully finalized action group")
+	return nil
+}
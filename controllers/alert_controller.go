@@ -18,17 +18,28 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	config "github.com/aberestyak/elasticsearch-security-operator/config"
 	alerts "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/alerts"
 	"github.com/go-logr/logr"
-	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
@@ -36,39 +47,50 @@ import (
 
 const alertFinalizer = "alert.security.rshbdev.ru/finalizer"
 
-var alertControllerLogger = log.WithFields(log.Fields{
-	"component": "AlertController",
-})
+// Annotation with comma-separated alert IDs or "all-active" to acknowledge once
+const acknowledgeAnnotation = "alert.security.rshbdev.ru/acknowledge"
+
+// Special value to acknowledge every active alert of monitor
+const allActiveAlerts = "all-active"
+
+// Number of latest alerts to summarize in CR status
+const monitorAlertsPageSize = 100
 
 // AlertReconciler reconciles a Alert object
 type AlertReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+	ChangeLog *ChangeLog
 }
 
 //+kubebuilder:rbac:groups=security.rshbdev.ru,resources=alerts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=security.rshbdev.ru,resources=alerts/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=security.rshbdev.ru,resources=alerts/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile main reconcile loop
 func (r *AlertReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, log, span := reconcileContext(ctx, r.Log, "Alert", req)
+	defer span.End()
 	desiredAlert := &securityv1alpha1.Alert{}
 	var err = r.Get(ctx, req.NamespacedName, desiredAlert)
 	if err != nil {
 		if kerrors.IsNotFound(err) {
-			alertControllerLogger.Info("Resource was deleted")
+			log.Info("Resource was deleted")
 			return ctrl.Result{}, nil
 		}
-		alertControllerLogger.Errorf("Error while reading CR Alert: %v", err)
+		log.Error(err, "Error while reading CR Alert")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	ctx = r.ChangeLog.WithSource(ctx, desiredAlert)
 
 	// Call finalyzer to clean up
 	isdesiredAlertToBeDeleted := desiredAlert.GetDeletionTimestamp() != nil
 	if isdesiredAlertToBeDeleted {
 		if controllerutil.ContainsFinalizer(desiredAlert, alertFinalizer) {
-			if err := r.FinalizeAlert(desiredAlert); err != nil {
+			if err := r.FinalizeAlert(ctx, desiredAlert); err != nil {
 				return ctrl.Result{}, err
 			}
 			controllerutil.RemoveFinalizer(desiredAlert, alertFinalizer)
@@ -92,7 +114,8 @@ func (r *AlertReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	// Map model to RoleAPISpec
 	alertAPIObject, err := MapAlertAPIObject(desiredAlert)
 	if err != nil {
-		roleControllerLogger.Errorf("Error when mapping models : %v", err)
+		log.Error(err, "Error when mapping models")
+		recordWarning(r.Recorder, desiredAlert, reasonInvalid, err.Error())
 		return ctrl.Result{}, err
 	}
 
@@ -100,40 +123,533 @@ func (r *AlertReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	for i, query := range alertAPIObject.Inputs {
 		alertAPIObject.Inputs[i].Search.Query = SanitizeQuery(query.Search.Query)
 	}
+	alertAPIObject.UIMetadata[monitorSpecHashKey] = MonitorSpecHash(alertAPIObject)
 
 	jsonAlert, err := json.Marshal(alertAPIObject)
 	if err != nil {
-		alertControllerLogger.Errorf("Error when marhaling alert object: %v", err)
+		log.Error(err, "Error when marhaling alert object")
+		return ctrl.Result{}, err
+	}
+	log = log.WithValues("monitor", alertAPIObject.Name)
+	ctx = ctrl.LoggerInto(ctx, log)
+	// Refuse to adopt monitor, which belongs to Alert from another namespace
+	conflict, err := CheckMonitorNameConflict(ctx, r.Client, desiredAlert, alertAPIObject.Name)
+	if err != nil {
+		log.Error(err, "Error when checking monitor name conflicts")
+		return ctrl.Result{}, err
+	}
+	if conflict != "" {
+		log.Info("Alert conflicts with another alert", "reason", conflict)
+		recordWarning(r.Recorder, desiredAlert, reasonConflict, conflict)
+		if err := SetAlertStatus(ctx, r, desiredAlert, "Conflict", []byte(conflict), desiredAlert.Status.Monitor.ID); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	// Find monitor in elasticsearch: by ID from status or by name, if status was lost
+	monitorID, liveMonitor, err := GetLiveMonitor(ctx, desiredAlert.Status.Monitor.ID, alertAPIObject.Name)
+	if err != nil {
+		log.Error(err, "Error when getting existing alert")
 		return ctrl.Result{}, err
 	}
+	// Monitor was found by name: refuse to take over monitor, created by hand or by another operator
+	if monitorID != "" && monitorID != desiredAlert.Status.Monitor.ID {
+		if conflict := CheckOwnership("monitor", alertAPIObject.Name, MonitorOwner(liveMonitor), desiredAlert.UID, desiredAlert.Spec.Adopt, false); conflict != "" {
+			log.Info("Alert conflicts with existing monitor", "reason", conflict)
+			recordWarning(r.Recorder, desiredAlert, reasonConflict, conflict)
+			if err := SetAlertStatus(ctx, r, desiredAlert, "Conflict", []byte(conflict), desiredAlert.Status.Monitor.ID); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+	}
+	isMonitorChanged := monitorID == "" || !IsMonitorEqual(liveMonitor, alertAPIObject)
+	// Render trigger action templates before saving changes
+	if isMonitorChanged {
+		templates, valid, hasWarnings := RenderAlertTemplates(alertAPIObject)
+		desiredAlert.Status.Templates = templates
+		// Optional variables are empty in some executions, but they are valid
+		if valid && hasWarnings {
+			log.Info("Alert templates use optional variables")
+			recordWarning(r.Recorder, desiredAlert, reasonInvalid, "Templates use variables, which may be empty, see status.templates")
+		}
+		if !valid {
+			log.Info("Alert has invalid templates, skip deploying")
+			recordWarning(r.Recorder, desiredAlert, reasonInvalid, "Template validation failed, see status.templates")
+			if err := SetAlertStatus(ctx, r, desiredAlert, "Invalid", []byte("Template validation failed"), desiredAlert.Status.Monitor.ID); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+	}
+	// Run monitor with dryrun before saving changes
+	if desiredAlert.Spec.ValidateOnApply && isMonitorChanged {
+		validation, err := ValidateMonitor(ctx, jsonAlert)
+		if err != nil {
+			log.Error(err, "Error when validating alert")
+			return ctrl.Result{}, err
+		}
+		desiredAlert.Status.Validation = validation
+		if !validation.Compiled {
+			log.Info("Alert failed validation, skip deploying")
+			recordWarning(r.Recorder, desiredAlert, reasonInvalid, "Monitor validation failed")
+			if err := SetAlertStatus(ctx, r, desiredAlert, "Error", []byte("Monitor validation failed"), desiredAlert.Status.Monitor.ID); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+	}
 	// New object created
-	if desiredAlert.Status.Monitor.ID == "" {
-		alertID, responseResult, responseBody, err := MakeAPIRequest("POST", config.AppConfig.ElasticsearchAlertAPIPath, jsonAlert)
+	if monitorID == "" {
+		alertID, responseResult, responseBody, err := MakeAPIRequest(ctx, "POST", config.AppConfig.ElasticsearchAlertAPIPath, jsonAlert)
 		if err != nil {
-			alertControllerLogger.Errorf("Error when creating new alert: %v", err.Error())
+			log.Error(err, "Error when creating new alert")
 			return ctrl.Result{}, err
 		}
-		if err := SetAlertStatus(r, desiredAlert, responseResult, responseBody, alertID); err != nil {
+		recordAPIResult(r.Recorder, desiredAlert, responseResult, reasonCreated, "Created monitor "+alertAPIObject.Name, responseBody)
+		if err := SetAlertStatus(ctx, r, desiredAlert, responseResult, responseBody, alertID); err != nil {
 			return ctrl.Result{}, err
 		}
-		alertControllerLogger.Infof("Created new alert: %v. Status: %v", desiredAlert.Name, desiredAlert.Status.Monitor.Status)
+		log.Info("Created new monitor", "state", desiredAlert.Status.Monitor.Status)
 
-		// Modified existing object
-	} else {
-		// Don't update new and deleted alerts
-		if desiredAlert.Generation > 1 && !isdesiredAlertToBeDeleted {
-			alertID, responseResult, responseBody, err := MakeAPIRequest("PUT", config.AppConfig.ElasticsearchAlertAPIPath+"/"+desiredAlert.Status.Monitor.ID, jsonAlert)
-			if err != nil {
-				alertControllerLogger.Errorf("Error when updating alert: %v", err.Error())
-				return ctrl.Result{}, err
+		// Existing monitor was changed outside or CR was modified
+	} else if isMonitorChanged {
+		reason := updateReason(desiredAlert.Generation, desiredAlert.Status.ObservedGeneration)
+		if reason == reasonDriftCorrected {
+			RecordDriftCorrection("monitor")
+		}
+		alertID, responseResult, responseBody, err := MakeAPIRequest(ctx, "PUT", config.AppConfig.ElasticsearchAlertAPIPath+"/"+monitorID, jsonAlert)
+		if err != nil {
+			log.Error(err, "Error when updating alert")
+			return ctrl.Result{}, err
+		}
+		if alertID == "" {
+			alertID = monitorID
+		}
+		recordAPIResult(r.Recorder, desiredAlert, responseResult, reason, reason+" monitor "+alertAPIObject.Name, responseBody)
+		if err := SetAlertStatus(ctx, r, desiredAlert, responseResult, responseBody, alertID); err != nil {
+			return ctrl.Result{}, err
+		}
+		log.Info("Updated monitor", "state", desiredAlert.Status.Monitor.Status)
+
+		// Adopt existing monitor with the same spec
+	} else if desiredAlert.Status.Monitor.ID != monitorID {
+		if err := SetAlertStatus(ctx, r, desiredAlert, "Deployed", nil, monitorID); err != nil {
+			return ctrl.Result{}, err
+		}
+		recordNormal(r.Recorder, desiredAlert, "Adopted", "Adopted existing monitor %v", alertAPIObject.Name)
+		log.Info("Adopted existing monitor", "monitorID", monitorID)
+	}
+	// Refresh firing state of deployed monitor periodically
+	if desiredAlert.Status.Monitor.ID != "" && desiredAlert.Status.Monitor.Status == "Deployed" {
+		RecordSuccessfulSync("alert", desiredAlert)
+		if err := AcknowledgeAlerts(ctx, r, desiredAlert); err != nil {
+			log.Error(err, "Error when acknowledging alerts")
+		}
+		if err := UpdateAlertState(ctx, r, desiredAlert); err != nil {
+			log.Error(err, "Error when updating alert state")
+		}
+	}
+	return ctrl.Result{RequeueAfter: config.AppConfig.AlertStateRefreshInterval}, nil
+}
+
+// AcknowledgeAlerts - acknowledge active alerts, requested in spec or annotation. Spec is applied once per generation,
+// so alerts are not acknowledged again on every refresh
+func AcknowledgeAlerts(ctx context.Context, r *AlertReconciler, alert *securityv1alpha1.Alert) error {
+	var requestedAlerts []string
+	previous := alert.Status.Acknowledgement
+	isSpecPending := len(alert.Spec.Acknowledge) > 0 && (previous == nil || previous.ObservedGeneration != alert.Generation)
+	if isSpecPending {
+		requestedAlerts = append(requestedAlerts, alert.Spec.Acknowledge...)
+	}
+	annotation, hasAnnotation := alert.GetAnnotations()[acknowledgeAnnotation]
+	if hasAnnotation {
+		for _, alertID := range strings.Split(annotation, ",") {
+			requestedAlerts = append(requestedAlerts, strings.TrimSpace(alertID))
+		}
+	}
+	if len(requestedAlerts) == 0 {
+		return nil
+	}
+	// Acknowledge only active alerts, so already acknowledged are not sent again
+	activeAlerts, err := GetMonitorAlerts(ctx, alert.Status.Monitor.ID, "ACTIVE")
+	if err != nil {
+		return err
+	}
+	var alertsToAcknowledge []string
+	for _, activeAlert := range activeAlerts {
+		if containsString(requestedAlerts, allActiveAlerts) || containsString(requestedAlerts, activeAlert.ID) {
+			alertsToAcknowledge = append(alertsToAcknowledge, activeAlert.ID)
+		}
+	}
+	acknowledgement := &securityv1alpha1.StatusAcknowledgement{Time: metav1.Now()}
+	if len(alertsToAcknowledge) > 0 {
+		if acknowledgement, err = CallAcknowledgeAPI(ctx, alert.Status.Monitor.ID, alertsToAcknowledge); err != nil {
+			recordWarning(r.Recorder, alert, reasonAcknowledgeFailed, "Failed to acknowledge alerts: "+err.Error())
+			return err
+		}
+	}
+	if len(alertsToAcknowledge) > 0 || isSpecPending {
+		if isSpecPending {
+			acknowledgement.ObservedGeneration = alert.Generation
+		} else if previous != nil {
+			acknowledgement.ObservedGeneration = previous.ObservedGeneration
+		}
+		alert.Status.Acknowledgement = acknowledgement
+		if err := r.Client.Status().Update(ctx, alert); err != nil {
+			return errors.New("Error when updating alert status: " + err.Error())
+		}
+		if len(acknowledgement.Alerts) > 0 {
+			recordNormal(r.Recorder, alert, reasonAcknowledged, "Acknowledged alerts: %v", strings.Join(acknowledgement.Alerts, ", "))
+		}
+		if len(acknowledgement.Failed) > 0 {
+			recordWarning(r.Recorder, alert, reasonAcknowledgeFailed, "Failed to acknowledge alerts: "+strings.Join(acknowledgement.Failed, ", "))
+		}
+		ctrl.LoggerFrom(ctx).Info("Acknowledged alerts", "alerts", acknowledgement.Alerts)
+	}
+	// Annotation is a one-time request
+	if hasAnnotation {
+		delete(alert.Annotations, acknowledgeAnnotation)
+		if err := r.Update(ctx, alert); err != nil {
+			return errors.New("Error when removing acknowledge annotation: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// CallAcknowledgeAPI - make request to acknowledge passed monitor's alerts
+func CallAcknowledgeAPI(ctx context.Context, monitorID string, alertIDs []string) (*securityv1alpha1.StatusAcknowledgement, error) {
+	acknowledgeJSON, _ := json.Marshal(alerts.AcknowledgeRequest{Alerts: alertIDs})
+	_, responseResult, responseBody, err := MakeAPIRequest(ctx, "POST", config.AppConfig.ElasticsearchAlertAPIPath+"/"+monitorID+"/_acknowledge/alerts", acknowledgeJSON)
+	if err != nil {
+		return nil, err
+	}
+	if responseResult == "Error" {
+		return nil, errors.New(responseText(responseBody))
+	}
+	var acknowledgeResult alerts.AcknowledgeResponse
+	if err := json.Unmarshal(responseBody, &acknowledgeResult); err != nil {
+		return nil, errors.New("Error when unmarshaling acknowledge result: " + err.Error())
+	}
+	acknowledgement := &securityv1alpha1.StatusAcknowledgement{
+		Time:   metav1.Now(),
+		Alerts: acknowledgeResult.Success,
+	}
+	for _, failed := range acknowledgeResult.Failed {
+		acknowledgement.Failed = append(acknowledgement.Failed, string(failed))
+	}
+	return acknowledgement, nil
+}
+
+// UpdateAlertState - get alerts, generated by monitor, and store their state in CR status, if changed
+func UpdateAlertState(ctx context.Context, r *AlertReconciler, alert *securityv1alpha1.Alert) error {
+	alertsState, err := GetMonitorAlertsState(ctx, alert.Status.Monitor.ID)
+	if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(alert.Status.Alerts, alertsState) {
+		return nil
+	}
+	alert.Status.Alerts = alertsState
+	if err := r.Client.Status().Update(ctx, alert); err != nil {
+		return errors.New("Error when updating alert status: " + err.Error())
+	}
+	return nil
+}
+
+// GetMonitorAlerts - get latest monitor's alerts in passed state
+func GetMonitorAlerts(ctx context.Context, monitorID, alertState string) ([]alerts.MonitorAlert, error) {
+	query := url.Values{
+		"monitorId":  []string{monitorID},
+		"alertState": []string{alertState},
+		"sortString": []string{"start_time"},
+		"sortOrder":  []string{"desc"},
+		"size":       []string{strconv.Itoa(monitorAlertsPageSize)},
+	}
+	_, responseResult, responseBody, err := MakeAPIRequest(ctx, "GET", config.AppConfig.ElasticsearchAlertAPIPath+"/alerts?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if responseResult == "Error" {
+		return nil, errors.New("Error when getting alerts: " + responseText(responseBody))
+	}
+	var alertsResult alerts.MonitorAlertsResponse
+	if err := json.Unmarshal(responseBody, &alertsResult); err != nil {
+		return nil, errors.New("Error when unmarshaling alerts: " + err.Error())
+	}
+	return alertsResult.Alerts, nil
+}
+
+// GetMonitorAlertsState - query latest monitor's alerts and summarize their state
+func GetMonitorAlertsState(ctx context.Context, monitorID string) (*securityv1alpha1.StatusAlerts, error) {
+	monitorAlerts, err := GetMonitorAlerts(ctx, monitorID, "ALL")
+	if err != nil {
+		return nil, err
+	}
+	alertsState := &securityv1alpha1.StatusAlerts{}
+	for _, monitorAlert := range monitorAlerts {
+		switch monitorAlert.State {
+		case "ACTIVE":
+			alertsState.Active++
+		case "ACKNOWLEDGED":
+			alertsState.Acknowledged++
+		default:
+			continue
+		}
+		// Severity is a number from 1 (highest) to 5 (lowest)
+		if alertsState.Severity == "" || monitorAlert.Severity < alertsState.Severity {
+			alertsState.Severity = monitorAlert.Severity
+		}
+	}
+	switch {
+	case alertsState.Active > 0:
+		alertsState.State = "ACTIVE"
+	case alertsState.Acknowledged > 0:
+		alertsState.State = "ACKNOWLEDGED"
+	case len(monitorAlerts) > 0:
+		alertsState.State = monitorAlerts[0].State
+	}
+	if len(monitorAlerts) > 0 {
+		// Alerts are sorted by start time, so the first one is the latest
+		lastTriggered := metav1.NewTime(time.Unix(monitorAlerts[0].StartTime/1000, 0))
+		alertsState.LastTriggered = &lastTriggered
+	}
+	return alertsState, nil
+}
+
+// GetLiveMonitor - get monitor by ID or search it by name, if ID is empty or monitor was deleted
+func GetLiveMonitor(ctx context.Context, monitorID, name string) (string, *alerts.AlertAPISpec, error) {
+	if monitorID != "" {
+		monitorExists, monitorBody, err := GetExistingObject(ctx, config.AppConfig.ElasticsearchAlertAPIPath, monitorID)
+		if err != nil {
+			return "", nil, err
+		}
+		if monitorExists {
+			var existingMonitor alerts.MonitorGetResponse
+			if err := json.Unmarshal(monitorBody, &existingMonitor); err != nil {
+				return "", nil, errors.New("Error when unmarshaling existing alert: " + err.Error())
 			}
-			if err := SetAlertStatus(r, desiredAlert, responseResult, responseBody, alertID); err != nil {
-				return ctrl.Result{}, err
+			return monitorID, &existingMonitor.Monitor, nil
+		}
+		ctrl.LoggerFrom(ctx).Info("Monitor not found by ID, searching by name", "monitorID", monitorID)
+	}
+	return FindMonitorByName(ctx, name)
+}
+
+// FindMonitorByName - search existing monitor with exactly the same name
+func FindMonitorByName(ctx context.Context, name string) (string, *alerts.AlertAPISpec, error) {
+	searchQuery := map[string]interface{}{
+		"query": map[string]interface{}{
+			"match_phrase": map[string]string{"monitor.name": name},
+		},
+	}
+	searchQueryJSON, _ := json.Marshal(searchQuery)
+	_, responseResult, responseBody, err := MakeAPIRequest(ctx, "POST", config.AppConfig.ElasticsearchAlertAPIPath+"/_search", searchQueryJSON)
+	if err != nil {
+		return "", nil, err
+	}
+	if responseResult == "Error" {
+		// There is no alerting config index until the first monitor is created
+		if strings.Contains(string(responseBody), "index_not_found_exception") {
+			return "", nil, nil
+		}
+		return "", nil, errors.New("Error when searching alerts: " + responseText(responseBody))
+	}
+	var searchResult alerts.MonitorSearchResponse
+	if err := json.Unmarshal(responseBody, &searchResult); err != nil {
+		return "", nil, errors.New("Error when unmarshaling alerts search result: " + err.Error())
+	}
+	for _, hit := range searchResult.Hits.Hits {
+		// match_phrase can return monitors with similar names
+		if hit.Source.Name == name {
+			monitor := hit.Source
+			return hit.ID, &monitor, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// ValidateMonitor - execute monitor with dryrun and check that inputs and triggers were run without errors
+func ValidateMonitor(ctx context.Context, jsonAlert []byte) (*securityv1alpha1.StatusValidation, error) {
+	_, responseResult, responseBody, err := MakeAPIRequest(ctx, "POST", config.AppConfig.ElasticsearchAlertAPIPath+"/_execute?dryrun=true", jsonAlert)
+	if err != nil {
+		return nil, err
+	}
+	if responseResult == "Error" {
+		return &securityv1alpha1.StatusValidation{Compiled: false, Error: responseText(responseBody)}, nil
+	}
+	var executeResult alerts.MonitorExecuteResponse
+	if err := json.Unmarshal(responseBody, &executeResult); err != nil {
+		return nil, errors.New("Error when unmarshaling alert execution result: " + err.Error())
+	}
+	validation := &securityv1alpha1.StatusValidation{
+		Error:      executeResult.Error,
+		InputError: executeResult.InputResults.Error,
+		Compiled:   executeResult.Error == "" && executeResult.InputResults.Error == "",
+	}
+	for _, triggerResult := range executeResult.TriggerResults {
+		validation.Triggers = append(validation.Triggers, securityv1alpha1.StatusTriggerValidation{
+			Name:      triggerResult.Name,
+			Triggered: triggerResult.Triggered,
+			Error:     triggerResult.Error,
+		})
+		if triggerResult.Error != "" {
+			validation.Compiled = false
+		}
+	}
+	// Trigger results are returned as map, keep status stable between reconciles
+	sort.Slice(validation.Triggers, func(i, j int) bool {
+		return validation.Triggers[i].Name < validation.Triggers[j].Name
+	})
+	return validation, nil
+}
+
+// IsMonitorEqual - compare live and desired monitors, ignoring json formatting. Hash of desired spec is stored in
+// ui_metadata of applied monitor, so changed spec is detected without comparing queries. Elasticsearch returns
+// search queries in normalized form with default parameters, so desired queries are normalized and must be
+// contained in live queries, the rest of monitor, including triggers and schedule, must be equal
+func IsMonitorEqual(liveMonitor, desiredMonitor *alerts.AlertAPISpec) bool {
+	if liveMonitor == nil || desiredMonitor == nil {
+		return liveMonitor == desiredMonitor
+	}
+	if liveMonitor.UIMetadata[monitorSpecHashKey] != desiredMonitor.UIMetadata[monitorSpecHashKey] {
+		return false
+	}
+	live, desired := monitorWithoutQueries(liveMonitor), monitorWithoutQueries(desiredMonitor)
+	if live == nil || !reflect.DeepEqual(live, desired) || len(liveMonitor.Inputs) != len(desiredMonitor.Inputs) {
+		return false
+	}
+	for i := range desiredMonitor.Inputs {
+		if !isQueryEqual(liveMonitor.Inputs[i].Search.Query, desiredMonitor.Inputs[i].Search.Query) {
+			return false
+		}
+	}
+	return true
+}
+
+// isQueryEqual - check, that normalized desired search query is contained in live query, returned by Elasticsearch
+func isQueryEqual(liveQuery, desiredQuery json.RawMessage) bool {
+	var live, desired interface{}
+	if len(desiredQuery) == 0 {
+		return len(liveQuery) == 0
+	}
+	if err := json.Unmarshal(liveQuery, &live); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(desiredQuery, &desired); err != nil {
+		return false
+	}
+	return containsQuery(live, normalizeQuery(desired))
+}
+
+// normalizeQuery - rewrite query clauses, which Elasticsearch returns in another form: gt, gte, lt and lte
+// of range queries are returned as from, to, include_lower and include_upper
+func normalizeQuery(query interface{}) interface{} {
+	switch value := query.(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			normalized[key] = normalizeQuery(item)
+		}
+		if rangeQuery, ok := normalized["range"].(map[string]interface{}); ok {
+			for field, bounds := range rangeQuery {
+				if bounds, ok := bounds.(map[string]interface{}); ok {
+					rangeQuery[field] = normalizeRangeBounds(bounds)
+				}
+			}
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(value))
+		for i, item := range value {
+			normalized[i] = normalizeQuery(item)
+		}
+		return normalized
+	default:
+		return value
+	}
+}
+
+// normalizeRangeBounds - map gt, gte, lt and lte bounds of range query to from and to
+func normalizeRangeBounds(bounds map[string]interface{}) map[string]interface{} {
+	normalized := make(map[string]interface{}, len(bounds))
+	for key, value := range bounds {
+		switch key {
+		case "gt", "gte":
+			normalized["from"], normalized["include_lower"] = value, key == "gte"
+		case "lt", "lte":
+			normalized["to"], normalized["include_upper"] = value, key == "lte"
+		default:
+			normalized[key] = value
+		}
+	}
+	return normalized
+}
+
+// containsQuery - check, that every field of desired query is set in live query. Fields, added by Elasticsearch
+// with default values, are ignored, and short form of leaf query, like {"term": {"field": "value"}}, matches
+// full form with query or value parameter
+func containsQuery(live, desired interface{}) bool {
+	switch desiredValue := desired.(type) {
+	case map[string]interface{}:
+		liveValue, ok := live.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, item := range desiredValue {
+			if !containsQuery(liveValue[key], item) {
+				return false
 			}
-			alertControllerLogger.Infof("Updated alert: %v. Status: %v", desiredAlert.Name, desiredAlert.Status.Monitor.Status)
 		}
+		return true
+	case []interface{}:
+		liveValue, ok := live.([]interface{})
+		if !ok || len(liveValue) != len(desiredValue) {
+			return false
+		}
+		for i, item := range desiredValue {
+			if !containsQuery(liveValue[i], item) {
+				return false
+			}
+		}
+		return true
+	default:
+		if liveValue, ok := live.(map[string]interface{}); ok {
+			for _, key := range []string{"query", "value"} {
+				if item, ok := liveValue[key]; ok {
+					return reflect.DeepEqual(item, desired)
+				}
+			}
+			return false
+		}
+		return reflect.DeepEqual(live, desired)
+	}
+}
+
+// MonitorSpecHash - hash of monitor spec without ui_metadata
+func MonitorSpecHash(monitor *alerts.AlertAPISpec) string {
+	spec := *monitor
+	spec.UIMetadata = nil
+	specJSON, _ := json.Marshal(spec)
+	hash := sha256.Sum256(specJSON)
+	return hex.EncodeToString(hash[:])
+}
+
+// monitorWithoutQueries - generic json value of monitor without search queries and ui_metadata
+func monitorWithoutQueries(monitor *alerts.AlertAPISpec) interface{} {
+	spec := *monitor
+	spec.UIMetadata = nil
+	spec.Inputs = make([]alerts.MonitorInput, len(monitor.Inputs))
+	for i, input := range monitor.Inputs {
+		spec.Inputs[i] = input
+		spec.Inputs[i].Search.Query = nil
+	}
+	var value interface{}
+	specJSON, _ := json.Marshal(spec)
+	if err := json.Unmarshal(specJSON, &value); err != nil {
+		return nil
 	}
-	return ctrl.Result{}, nil
+	return value
 }
 
 // MapAlertAPIObject - map CRD model to API
@@ -143,23 +659,35 @@ func MapAlertAPIObject(alert *securityv1alpha1.Alert) (*alerts.AlertAPISpec, err
 	if err := json.Unmarshal(buf, &alertAPI); err != nil {
 		return nil, err
 	}
+	alertAPI.Name = EffectiveMonitorName(alert)
+	// Tag monitor, so it is not taken over by another resource or operator
+	alertAPI.UIMetadata = map[string]interface{}{ownershipKey: string(alert.UID)}
 	return &alertAPI, nil
 }
 
 // SetAlertStatus set status
-func SetAlertStatus(r *AlertReconciler, alert *securityv1alpha1.Alert, responseResult string, responseBody []byte, alertID string) error {
+func SetAlertStatus(ctx context.Context, r *AlertReconciler, alert *securityv1alpha1.Alert, responseResult string, responseBody []byte, alertID string) error {
+	if responseResult == "Deployed" {
+		alert.Status.ObservedGeneration = alert.Generation
+	}
+	// Alert in conflict keeps managed monitor, but doesn't claim conflicting name
+	name := EffectiveMonitorName(alert)
+	if responseResult == "Conflict" {
+		name = alert.Status.Monitor.Name
+	}
 	alert.Status.Monitor = securityv1alpha1.StatusMonitor{
-		Name:   alert.Name,
+		Name:   name,
 		ID:     alertID,
 		Status: responseResult,
 		Error: func(response string, responseBody []byte) string {
-			if response == "Error" {
-				return string(responseBody)
+			if response != "Deployed" {
+				return responseText(responseBody)
 			}
 			return ""
 		}(responseResult, responseBody),
 	}
-	if err := r.Client.Status().Update(context.TODO(), alert); err != nil {
+	setConflictCondition(&alert.Status.Conditions, alert.Generation, responseResult, alert.Status.Monitor.Error)
+	if err := r.Client.Status().Update(ctx, alert); err != nil {
 		return errors.New("Error when updating alert status: " + err.Error())
 	}
 	return nil
@@ -169,18 +697,29 @@ func SetAlertStatus(r *AlertReconciler, alert *securityv1alpha1.Alert, responseR
 func (r *AlertReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&securityv1alpha1.Alert{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: config.AppConfig.MaxConcurrentReconciles}).
 		Complete(r)
 }
 
 // FinalizeAlert delete alert
-func (r *AlertReconciler) FinalizeAlert(alert *securityv1alpha1.Alert) error {
+func (r *AlertReconciler) FinalizeAlert(ctx context.Context, alert *securityv1alpha1.Alert) error {
+	log := ctrl.LoggerFrom(ctx)
+	if deletionPolicy := EffectiveDeletionPolicy(alert.Spec.DeletionPolicy); KeepsObjects(deletionPolicy) {
+		log.Info("Keep monitor", "deletionPolicy", deletionPolicy)
+		recordNormal(r.Recorder, alert, reasonOrphaned, "Kept monitor with %v deletion policy", deletionPolicy)
+		ForgetSync("alert", alert)
+		return nil
+	}
 	if alert.Status.Monitor.ID != "" {
-		_, _, _, err := MakeAPIRequest("DELETE", config.AppConfig.ElasticsearchAlertAPIPath+"/"+alert.Status.Monitor.ID, nil)
+		_, _, _, err := MakeAPIRequest(ctx, "DELETE", config.AppConfig.ElasticsearchAlertAPIPath+"/"+alert.Status.Monitor.ID, nil)
 		if err != nil {
-			alertControllerLogger.Errorf("Error when finalyzing alert: %v", err.Error())
+			log.Error(err, "Error when finalyzing alert")
+			recordWarning(r.Recorder, alert, reasonFailed, err.Error())
 			return err
 		}
+		recordNormal(r.Recorder, alert, reasonDeleted, "Deleted monitor %v", alert.Status.Monitor.Name)
 	}
-	alertControllerLogger.Infof("Successfully finalized alert: %v", alert.Name)
+	ForgetSync("alert", alert)
+	log.Info("Successfully finalized alert")
 	return nil
 }
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+
+	alerts "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/alerts"
+)
+
+func TestIsMonitorEqual(t *testing.T) {
+	desired := &alerts.AlertAPISpec{
+		Name:    "errors",
+		Enabled: true,
+		Inputs: []alerts.MonitorInput{{Search: alerts.InputSearch{
+			Indices: []string{"logs-*"},
+			Query:   json.RawMessage(`{"query":{"range":{"@timestamp":{"gte":"now-1h"}}}}`),
+		}}},
+		UIMetadata: map[string]interface{}{ownershipKey: "uid"},
+	}
+	desired.UIMetadata[monitorSpecHashKey] = MonitorSpecHash(desired)
+	live := func(change func(*alerts.AlertAPISpec)) *alerts.AlertAPISpec {
+		monitor := *desired
+		monitor.Inputs = []alerts.MonitorInput{{Search: alerts.InputSearch{
+			Indices: []string{"logs-*"},
+			// Query, as it is returned by Elasticsearch
+			Query: json.RawMessage(`{"query":{"range":{"@timestamp":{"from":"now-1h","to":null,"include_lower":true,"include_upper":true,"boost":1}}}}`),
+		}}}
+		monitor.UIMetadata = map[string]interface{}{}
+		for key, value := range desired.UIMetadata {
+			monitor.UIMetadata[key] = value
+		}
+		change(&monitor)
+		return &monitor
+	}
+	tests := []struct {
+		name     string
+		live     *alerts.AlertAPISpec
+		expected bool
+	}{
+		{"normalized query", live(func(*alerts.AlertAPISpec) {}), true},
+		{"disabled", live(func(m *alerts.AlertAPISpec) { m.Enabled = false }), false},
+		{"query edited", live(func(m *alerts.AlertAPISpec) {
+			m.Inputs = []alerts.MonitorInput{{Search: alerts.InputSearch{
+				Indices: []string{"logs-*"},
+				Query:   json.RawMessage(`{"query":{"range":{"@timestamp":{"from":"now-1d","to":null,"include_lower":true,"include_upper":true,"boost":1}}}}`),
+			}}}
+		}), false},
+		{"schedule edited", live(func(m *alerts.AlertAPISpec) { m.Schedule.Period.Interval = 10 }), false},
+		{"trigger added", live(func(m *alerts.AlertAPISpec) { m.Triggers = []alerts.MonitorTrigger{{Name: "kibana"}} }), false},
+		{"saved in kibana", live(func(m *alerts.AlertAPISpec) { m.UIMetadata = map[string]interface{}{"schedule": "interval"} }), false},
+		{"applied by previous version", live(func(m *alerts.AlertAPISpec) { delete(m.UIMetadata, monitorSpecHashKey) }), false},
+		{"missing", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if equal := IsMonitorEqual(test.live, desired); equal != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, equal)
+			}
+		})
+	}
+}
+
+func TestIsQueryEqual(t *testing.T) {
+	tests := []struct {
+		name     string
+		live     string
+		desired  string
+		expected bool
+	}{
+		{"short form of leaf query", `{"query":{"term":{"level":{"value":"error","boost":1}}}}`, `{"query":{"term":{"level":"error"}}}`, true},
+		{"changed leaf query", `{"query":{"term":{"level":{"value":"warning","boost":1}}}}`, `{"query":{"term":{"level":"error"}}}`, false},
+		{"exclusive range", `{"query":{"range":{"count":{"from":5,"to":null,"include_lower":false,"include_upper":true}}}}`, `{"query":{"range":{"count":{"gt":5}}}}`, true},
+		{"inclusive range", `{"query":{"range":{"count":{"from":5,"to":null,"include_lower":false,"include_upper":true}}}}`, `{"query":{"range":{"count":{"gte":5}}}}`, false},
+		{"clause added", `{"query":{"bool":{"must":[{"match_all":{}},{"match_all":{}}],"adjust_pure_negative":true}}}`, `{"query":{"bool":{"must":[{"match_all":{}}]}}}`, false},
+		{"size changed", `{"size":10,"query":{"match_all":{"boost":1}}}`, `{"size":0,"query":{"match_all":{}}}`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if equal := isQueryEqual(json.RawMessage(test.live), json.RawMessage(test.desired)); equal != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, equal)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"encoding/json"
+	"time"
+
+	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+	alerts "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/alerts"
+	"github.com/aberestyak/elasticsearch-security-operator/internal/mustache"
+)
+
+// Fixed sample time keeps rendered preview in status stable between reconciles
+var sampleTemplatePeriodEnd = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// sampleAlertFields - documented fields of ctx.alert
+var sampleAlertFields = mustache.Optional{
+	"id", "version", "state", "severity", "error_message", "start_time", "end_time", "acknowledged_time", "last_notification_time",
+}
+
+// Sample ID of monitor, trigger and action, which are assigned by alerting plugin
+const sampleTemplateID = "sample-id"
+
+// RenderAlertTemplates - render subject and message templates of every trigger action with sample context.
+// Returns rendered preview, false, if any template has syntax errors or unknown variables, and true, if any template uses
+// optional variables, which may be empty
+func RenderAlertTemplates(monitor *alerts.AlertAPISpec) ([]securityv1alpha1.StatusTemplatePreview, bool, bool) {
+	var previews []securityv1alpha1.StatusTemplatePreview
+	valid, hasWarnings := true, false
+	for _, trigger := range monitor.Triggers {
+		context := SampleTemplateContext(monitor, trigger)
+		for _, action := range trigger.Actions {
+			preview := securityv1alpha1.StatusTemplatePreview{
+				Trigger: trigger.Name,
+				Action:  action.Name,
+			}
+			var err error
+			var warnings []string
+			if preview.Subject, warnings, err = renderTemplate(action.SubjectTemplate, context); err != nil {
+				preview.Error = "subject_template: " + err.Error()
+			}
+			for _, warning := range warnings {
+				preview.Warnings = append(preview.Warnings, "subject_template: "+warning)
+			}
+			if preview.Error == "" {
+				if preview.Message, warnings, err = renderTemplate(action.MessageTemplate, context); err != nil {
+					preview.Error = "message_template: " + err.Error()
+				}
+				for _, warning := range warnings {
+					preview.Warnings = append(preview.Warnings, "message_template: "+warning)
+				}
+			}
+			if preview.Error != "" {
+				valid = false
+			}
+			if len(preview.Warnings) > 0 {
+				hasWarnings = true
+			}
+			previews = append(previews, preview)
+		}
+	}
+	return previews, valid, hasWarnings
+}
+
+// SampleTemplateContext - build ctx, passed by alerting plugin to trigger action templates, with every documented variable.
+// Fields, set by alerting plugin, like IDs and times, get sample values
+func SampleTemplateContext(monitor *alerts.AlertAPISpec, trigger alerts.MonitorTrigger) map[string]interface{} {
+	periodEnd := sampleTemplatePeriodEnd
+	periodStart := periodEnd.Add(-schedulePeriodDuration(monitor.Schedule.Period))
+	sampleTime := float64(periodEnd.UnixNano() / int64(time.Millisecond))
+	// Search results depend on indices data, so they can't be validated
+	results := make([]interface{}, 0, len(monitor.Inputs))
+	for range monitor.Inputs {
+		results = append(results, mustache.Dynamic)
+	}
+	monitorValue, _ := toTemplateValue(monitor).(map[string]interface{})
+	if monitorValue == nil {
+		monitorValue = map[string]interface{}{}
+	}
+	monitorValue["_id"] = sampleTemplateID
+	monitorValue["_version"] = float64(1)
+	monitorValue["last_update_time"] = sampleTime
+	monitorValue["enabled_time"] = nil
+	if monitor.Enabled {
+		monitorValue["enabled_time"] = sampleTime
+	}
+	triggers, _ := monitorValue["triggers"].([]interface{})
+	for _, monitorTrigger := range triggers {
+		withSampleIDs(monitorTrigger)
+	}
+	triggerValue := withSampleIDs(toTemplateValue(trigger))
+	return map[string]interface{}{
+		"ctx": map[string]interface{}{
+			"monitor":     monitorValue,
+			"trigger":     triggerValue,
+			"results":     results,
+			"periodStart": periodStart.Format(time.RFC3339),
+			"periodEnd":   periodEnd.Format(time.RFC3339),
+			// Alert is null for new alerts and has fields of existing alert otherwise
+			"alert": sampleAlertFields,
+			"error": nil,
+		},
+	}
+}
+
+// withSampleIDs - set sample IDs of trigger and its actions
+func withSampleIDs(trigger interface{}) interface{} {
+	triggerValue, ok := trigger.(map[string]interface{})
+	if !ok {
+		return trigger
+	}
+	triggerValue["id"] = sampleTemplateID
+	actions, _ := triggerValue["actions"].([]interface{})
+	for _, action := range actions {
+		if actionValue, ok := action.(map[string]interface{}); ok {
+			actionValue["id"] = sampleTemplateID
+		}
+	}
+	return triggerValue
+}
+
+func renderTemplate(template alerts.TextTemplate, context map[string]interface{}) (string, []string, error) {
+	// Only mustache templates can be rendered by operator
+	if template.Lang != "" && template.Lang != "mustache" {
+		return "", nil, nil
+	}
+	return mustache.Render(template.Source, context)
+}
+
+func schedulePeriodDuration(period alerts.SchedulePeroid) time.Duration {
+	unit := time.Minute
+	switch period.Unit {
+	case "HOURS":
+		unit = time.Hour
+	case "DAYS":
+		unit = 24 * time.Hour
+	}
+	return time.Duration(period.Interval) * unit
+}
+
+// toTemplateValue - convert API object to generic json value, the same way as it is seen by alerting plugin
+func toTemplateValue(object interface{}) interface{} {
+	var value interface{}
+	buf, _ := json.Marshal(object)
+	if err := json.Unmarshal(buf, &value); err != nil {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"testing"
+
+	alerts "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/alerts"
+)
+
+func TestRenderAlertTemplates(t *testing.T) {
+	monitor := func(message string) *alerts.AlertAPISpec {
+		return &alerts.AlertAPISpec{
+			Name:    "errors",
+			Enabled: true,
+			Triggers: []alerts.MonitorTrigger{{
+				Name: "high",
+				Actions: []alerts.TriggerAction{{
+					Name:            "slack",
+					MessageTemplate: alerts.TextTemplate{Source: message, Lang: "mustache"},
+				}},
+			}},
+		}
+	}
+	tests := []struct {
+		name        string
+		message     string
+		valid       bool
+		hasWarnings bool
+	}{
+		{"documented variables", "{{ctx.monitor._id}} {{ctx.monitor.enabled_time}} {{ctx.monitor.last_update_time}} {{ctx.trigger.id}} {{ctx.trigger.actions.0.id}} {{#ctx.alert}}{{acknowledged_time}}{{/ctx.alert}}", true, false},
+		{"optional variable", "{{ctx.alert.acknowledged_time}}", true, true},
+		{"unknown variable", "{{ctx.monitor.nmae}}", false, false},
+		{"syntax error", "{{#ctx.monitor}}", false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			previews, valid, hasWarnings := RenderAlertTemplates(monitor(test.message))
+			if valid != test.valid || hasWarnings != test.hasWarnings {
+				t.Errorf("expected valid %v and warnings %v, got %v and %v: %+v", test.valid, test.hasWarnings, valid, hasWarnings, previews)
+			}
+		})
+	}
+}
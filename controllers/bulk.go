@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	config "github.com/aberestyak/elasticsearch-security-operator/config"
+	"github.com/aberestyak/elasticsearch-security-operator/internal/tracing"
+)
+
+// bulkBatchers - batchers by API path, empty if bulk requests are disabled
+var bulkBatchers = map[string]*bulkBatcher{}
+
+// bulkWrite - PUT of single object, batched into JSON-Patch of API collection
+type bulkWrite struct {
+	ctx  context.Context
+	name string
+	body []byte
+	done chan bulkWriteResult
+}
+
+type bulkWriteResult struct {
+	responseResult string
+	responseBody   []byte
+	err            error
+}
+
+// bulkBatcher - collect writes of objects of one API during window and apply them with one request
+type bulkBatcher struct {
+	path    string
+	window  time.Duration
+	maxSize int
+
+	mu     sync.Mutex
+	writes []*bulkWrite
+	timer  *time.Timer
+}
+
+// EnableBulkRequests - batch writes of roles, role mappings and users from concurrent reconciles
+func EnableBulkRequests(window time.Duration, maxSize int) {
+	for _, path := range []string{
+		config.AppConfig.ElasticsearchRoleAPIPath,
+		config.AppConfig.ElasticsearchRoleMappingAPIPath,
+		config.AppConfig.ElasticsearchUserAPIPath,
+	} {
+		bulkBatchers[path] = &bulkBatcher{path: path, window: window, maxSize: maxSize}
+	}
+}
+
+// PutObject - create or update object with PUT request or in batch, if bulk requests are enabled for API.
+// Returns the same result as MakeAPIRequest
+func PutObject(ctx context.Context, path, name string, jsonBody []byte) (string, []byte, error) {
+	batcher := bulkBatchers[path]
+	if batcher == nil {
+		_, responseResult, responseBody, err := MakeAPIRequest(ctx, "PUT", path+"/"+name, jsonBody)
+		return responseResult, responseBody, err
+	}
+	write := &bulkWrite{ctx: ctx, name: name, body: jsonBody, done: make(chan bulkWriteResult, 1)}
+	batcher.add(write)
+	select {
+	case result := <-write.done:
+		return result.responseResult, result.responseBody, result.err
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+}
+
+// add - queue write and schedule flush after window, or flush now, if batch is full
+func (b *bulkBatcher) add(write *bulkWrite) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writes = append(b.writes, write)
+	if len(b.writes) >= b.maxSize {
+		if b.timer != nil {
+			b.timer.Stop()
+			b.timer = nil
+		}
+		go b.flush()
+		return
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+}
+
+func (b *bulkBatcher) flush() {
+	b.mu.Lock()
+	writes := b.writes
+	b.writes, b.timer = nil, nil
+	b.mu.Unlock()
+	// Reconcile may be cancelled, while its write waits in batch, and must not be applied after that
+	pending := writes[:0]
+	for _, write := range writes {
+		if err := write.ctx.Err(); err != nil {
+			write.done <- bulkWriteResult{err: err}
+			continue
+		}
+		pending = append(pending, write)
+	}
+	if len(pending) > 0 {
+		b.flushWrites(pending)
+	}
+}
+
+// batchContext - context of batched request with logger and trace of the first request in batch
+func batchContext(ctx context.Context, size int) context.Context {
+	log := ctrl.LoggerFrom(ctx).WithName("Bulk").WithValues("batchSize", size)
+	return tracing.ContextWithSpan(ctrl.LoggerInto(context.Background(), log), tracing.FromContext(ctx))
+}
+
+// flushWrites - apply writes with one JSON-Patch. Patch is applied atomically, so if it is rejected,
+// every write is retried with PUT to find out which one is invalid
+func (b *bulkBatcher) flushWrites(writes []*bulkWrite) {
+	ctx := batchContext(writes[0].ctx, len(writes))
+	log := ctrl.LoggerFrom(ctx)
+	patch := make([]map[string]interface{}, 0, len(writes))
+	audited := false
+	for _, write := range writes {
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  "/" + jsonPointerEscape(write.name),
+			"value": json.RawMessage(write.body),
+		})
+		audited = audited || isAudited(write.ctx, "PUT", b.path+"/"+write.name)
+	}
+	// Change log takes state of objects before and after patch from collection instead of GET of every object.
+	// If collection can't be read, writes are made one by one
+	var before map[string]json.RawMessage
+	var err error
+	if audited {
+		if before = b.getCollection(ctx); before == nil {
+			err = errors.New("Can't get state of objects before patch")
+		}
+	}
+	var patchJSON []byte
+	if err == nil {
+		patchJSON, err = json.Marshal(patch)
+	}
+	var responseResult string
+	var responseBody []byte
+	if err == nil {
+		_, responseResult, responseBody, err = MakeAPIRequest(ctx, "PATCH", b.path, patchJSON)
+	}
+	if err == nil && responseResult == "Deployed" {
+		var after map[string]json.RawMessage
+		if audited {
+			after = b.getCollection(ctx)
+		}
+		for _, write := range writes {
+			change := startChangeWithState(write.ctx, "PUT", b.path+"/"+write.name, collectionObject(before, write.name))
+			if after != nil {
+				change.finishWithState(write.ctx, "", collectionObject(after, write.name))
+			} else {
+				change.finish(write.ctx, "", responseResult)
+			}
+			write.done <- bulkWriteResult{responseResult: responseResult, responseBody: responseBody}
+		}
+		return
+	}
+	if err == nil {
+		log.Info("Bulk patch is rejected, fall back to PUT requests", "response", responseText(responseBody))
+	} else {
+		log.Info("Bulk patch failed, fall back to PUT requests", "error", err.Error())
+	}
+	// PUT requests record their changes themselves
+	for _, write := range writes {
+		_, responseResult, responseBody, err := MakeAPIRequest(write.ctx, "PUT", b.path+"/"+write.name, write.body)
+		write.done <- bulkWriteResult{responseResult: responseResult, responseBody: responseBody, err: err}
+	}
+}
+
+// getCollection - get all objects of API, nil if request failed
+func (b *bulkBatcher) getCollection(ctx context.Context) map[string]json.RawMessage {
+	_, responseResult, responseBody, err := MakeAPIRequest(ctx, "GET", b.path, nil)
+	if err != nil || responseResult != "Deployed" {
+		ctrl.LoggerFrom(ctx).Info("Can't get collection", "error", err, "response", responseText(responseBody))
+		return nil
+	}
+	objects := map[string]json.RawMessage{}
+	if err := json.Unmarshal(responseBody, &objects); err != nil {
+		ctrl.LoggerFrom(ctx).Info("Can't unmarshal collection", "error", err.Error())
+		return nil
+	}
+	return objects
+}
+
+// collectionObject - object from collection in the same form, as response to GET of single object, nil if it doesn't exist
+func collectionObject(objects map[string]json.RawMessage, name string) []byte {
+	object, ok := objects[name]
+	if !ok {
+		return nil
+	}
+	body, _ := json.Marshal(map[string]json.RawMessage{name: object})
+	return body
+}
+
+// jsonPointerEscape - escape object name for JSON-Patch path
+func jsonPointerEscape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
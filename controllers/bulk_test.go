@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+	config "github.com/aberestyak/elasticsearch-security-operator/config"
+)
+
+// fakeSecurityAPI - roles collection, which accepts PATCH unless it is rejected, and PUT of single role
+type fakeSecurityAPI struct {
+	mu           sync.Mutex
+	rejectPatch  bool
+	requests     []string
+	patchedNames []string
+}
+
+func (f *fakeSecurityAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	switch {
+	case r.Method == http.MethodPatch && f.rejectPatch:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"status":"BAD_REQUEST","message":"invalid"}`))
+	case r.Method == http.MethodPatch:
+		body, _ := ioutil.ReadAll(r.Body)
+		var patch []struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}
+		_ = json.Unmarshal(body, &patch)
+		for _, op := range patch {
+			f.patchedNames = append(f.patchedNames, op.Op+" "+op.Path)
+		}
+		_, _ = w.Write([]byte(`{"status":"OK","message":"Resource updated."}`))
+	case r.Method == http.MethodGet && r.URL.Path == "/roles":
+		_, _ = w.Write([]byte(`{"a":{"cluster_permissions":["x"]},"b/c":{"cluster_permissions":["y"]}}`))
+	case r.Method == http.MethodGet:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"status":"NOT_FOUND"}`))
+	default:
+		_, _ = w.Write([]byte(`{"status":"OK"}`))
+	}
+}
+
+func withFakeSecurityAPI(t *testing.T, api *fakeSecurityAPI) {
+	server := httptest.NewServer(api)
+	host := defaultRequest.Cfg.Host
+	defaultRequest.Cfg.Host = server.URL
+	bulkBatchers["roles"] = &bulkBatcher{path: "roles", window: 50 * time.Millisecond, maxSize: 10}
+	t.Cleanup(func() {
+		server.Close()
+		defaultRequest.Cfg.Host = host
+		delete(bulkBatchers, "roles")
+	})
+}
+
+// concurrently - run function for every name like concurrent reconciles
+func concurrently(names []string, run func(name string)) {
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			run(name)
+		}(name)
+	}
+	wg.Wait()
+}
+
+func TestBulkWrites(t *testing.T) {
+	api := &fakeSecurityAPI{}
+	withFakeSecurityAPI(t, api)
+	concurrently([]string{"a", "b/c", "d"}, func(name string) {
+		responseResult, _, err := PutObject(context.Background(), "roles", name, []byte(`{}`))
+		if err != nil || responseResult != "Deployed" {
+			t.Errorf("role %v is not deployed: %v %v", name, responseResult, err)
+		}
+	})
+	if len(api.requests) != 1 || api.requests[0] != "PATCH /roles" {
+		t.Fatalf("expected single patch, got %v", api.requests)
+	}
+	if len(api.patchedNames) != 3 || !containsString(api.patchedNames, "add /b~1c") {
+		t.Fatalf("unexpected patch %v", api.patchedNames)
+	}
+}
+
+func TestBulkWritesFallback(t *testing.T) {
+	api := &fakeSecurityAPI{rejectPatch: true}
+	withFakeSecurityAPI(t, api)
+	concurrently([]string{"a", "d"}, func(name string) {
+		responseResult, _, err := PutObject(context.Background(), "roles", name, []byte(`{}`))
+		if err != nil || responseResult != "Deployed" {
+			t.Errorf("role %v is not deployed: %v %v", name, responseResult, err)
+		}
+	})
+	if len(api.requests) != 3 || !containsString(api.requests, "PUT /roles/a") || !containsString(api.requests, "PUT /roles/d") {
+		t.Fatalf("expected patch and PUT of every role, got %v", api.requests)
+	}
+}
+
+func TestBulkReads(t *testing.T) {
+	api := &fakeSecurityAPI{}
+	withFakeSecurityAPI(t, api)
+	concurrently([]string{"a", "d"}, func(name string) {
+		if exists, _, err := GetExistingObject(context.Background(), "roles", name); err != nil || exists {
+			t.Errorf("unexpected role %v: %v %v", name, exists, err)
+		}
+	})
+	// Reads aren't batched, so the whole collection isn't read
+	if len(api.requests) != 2 || !containsString(api.requests, "GET /roles/a") || !containsString(api.requests, "GET /roles/d") {
+		t.Fatalf("expected GET of every role, got %v", api.requests)
+	}
+}
+
+func TestBulkWritesCancelled(t *testing.T) {
+	api := &fakeSecurityAPI{}
+	withFakeSecurityAPI(t, api)
+	cancelled, cancel := context.WithCancel(context.Background())
+	concurrently([]string{"a", "d"}, func(name string) {
+		ctx := context.Background()
+		if name == "d" {
+			ctx = cancelled
+			time.AfterFunc(10*time.Millisecond, cancel)
+		}
+		responseResult, _, err := PutObject(ctx, "roles", name, []byte(`{}`))
+		if name == "d" && err == nil {
+			t.Errorf("write of role %v isn't cancelled: %v", name, responseResult)
+		}
+		if name == "a" && (err != nil || responseResult != "Deployed") {
+			t.Errorf("role %v is not deployed: %v %v", name, responseResult, err)
+		}
+	})
+	// Write of role a returns after patch, so the whole batch is flushed
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.patchedNames) != 1 || api.patchedNames[0] != "add /a" {
+		t.Fatalf("expected patch of role a only, got %v", api.patchedNames)
+	}
+}
+
+func TestBulkWritesChangeLog(t *testing.T) {
+	api := &fakeSecurityAPI{}
+	withFakeSecurityAPI(t, api)
+	rolePath := config.AppConfig.ElasticsearchRoleAPIPath
+	config.AppConfig.ElasticsearchRoleAPIPath = "roles"
+	t.Cleanup(func() { config.AppConfig.ElasticsearchRoleAPIPath = rolePath })
+	scheme := runtime.NewScheme()
+	_ = securityv1alpha1.AddToScheme(scheme)
+	changeLogClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.WithValue(context.Background(), changeSourceKey{}, &changeSource{log: &ChangeLog{Client: changeLogClient}})
+	concurrently([]string{"a", "d"}, func(name string) {
+		responseResult, _, err := PutObject(ctx, "roles", name, []byte(`{}`))
+		if err != nil || responseResult != "Deployed" {
+			t.Errorf("role %v is not deployed: %v %v", name, responseResult, err)
+		}
+	})
+	// State of objects is taken from collection before and after patch
+	expected := []string{"GET /roles", "PATCH /roles", "GET /roles"}
+	if len(api.requests) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, api.requests)
+	}
+	for i := range expected {
+		if api.requests[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, api.requests)
+		}
+	}
+	// Fake collection isn't changed by patch: existing role a is unchanged, role d is created
+	changeLogs := &securityv1alpha1.SecurityChangeLogList{}
+	if err := changeLogClient.List(context.Background(), changeLogs); err != nil {
+		t.Fatal(err)
+	}
+	if len(changeLogs.Items) != 1 || changeLogs.Items[0].Spec.Name != "d" || changeLogs.Items[0].Spec.Operation != securityv1alpha1.ChangeOperationCreate {
+		t.Fatalf("expected creation of role d, got %v", changeLogs.Items)
+	}
+}
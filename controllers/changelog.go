@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+	elasticsearch_api_client "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch"
+)
+
+const (
+	changeLogKindLabel     = "security.rshbdev.ru/kind"
+	changeLogCleanupPeriod = time.Hour
+	maxChanges             = 100
+	maxChangeValueLength   = 1024
+)
+
+var nonNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+//+kubebuilder:rbac:groups=security.rshbdev.ru,resources=securitychangelogs,verbs=get;list;watch;create;delete
+
+// ChangeLog - append-only audit trail of elasticsearch objects changes in SecurityChangeLog resources.
+// Records are created for successful mutations and are deleted after Retention, zero Retention keeps them forever
+type ChangeLog struct {
+	Client    client.Client
+	Retention time.Duration
+}
+
+type changeSourceKey struct{}
+
+// changeSource - custom resource, which reconcile makes changes
+type changeSource struct {
+	log    *ChangeLog
+	source securityv1alpha1.SecurityChangeSource
+}
+
+// pendingChange - mutation in progress with state of elasticsearch object before it
+type pendingChange struct {
+	*changeSource
+	method string
+	path   string
+	before []byte
+}
+
+// WithSource - record changes, made with returned context, as caused by obj. No-op, if change log is disabled
+func (c *ChangeLog) WithSource(ctx context.Context, obj client.Object) context.Context {
+	if c == nil {
+		return ctx
+	}
+	gvk, err := apiutil.GVKForObject(obj, c.Client.Scheme())
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Error when getting kind of resource for change log")
+		return ctx
+	}
+	source := securityv1alpha1.SecurityChangeSource{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+		Generation: obj.GetGeneration(),
+	}
+	source.Manager, source.ManagerTime = specManager(obj.GetManagedFields())
+	return context.WithValue(ctx, changeSourceKey{}, &changeSource{log: c, source: source})
+}
+
+// specManager - field manager, which made the latest change of spec. Status and finalizer updates don't touch spec
+func specManager(managedFields []metav1.ManagedFieldsEntry) (string, *metav1.Time) {
+	var latest *metav1.ManagedFieldsEntry
+	for i := range managedFields {
+		entry := &managedFields[i]
+		if entry.FieldsV1 == nil || !strings.Contains(string(entry.FieldsV1.Raw), `"f:spec"`) {
+			continue
+		}
+		if latest == nil || (entry.Time != nil && (latest.Time == nil || latest.Time.Before(entry.Time))) {
+			latest = entry
+		}
+	}
+	if latest == nil {
+		return "", nil
+	}
+	return latest.Manager, latest.Time
+}
+
+// startChange - fetch state of elasticsearch object before mutation. Returns nil, if request isn't audited
+func startChange(ctx context.Context, method, path string) *pendingChange {
+	if !isAudited(ctx, method, path) {
+		return nil
+	}
+	var before []byte
+	if method != http.MethodPost {
+		before = fetchObjectState(ctx, path)
+	}
+	return startChangeWithState(ctx, method, path, before)
+}
+
+// startChangeWithState - start change with known state of elasticsearch object before mutation, nil if it doesn't exist.
+// Returns nil, if request isn't audited
+func startChangeWithState(ctx context.Context, method, path string, before []byte) *pendingChange {
+	if !isAudited(ctx, method, path) {
+		return nil
+	}
+	return &pendingChange{changeSource: ctx.Value(changeSourceKey{}).(*changeSource), method: method, path: path, before: before}
+}
+
+// isAudited - request is mutation, made by reconcile with change source
+func isAudited(ctx context.Context, method, path string) bool {
+	source, _ := ctx.Value(changeSourceKey{}).(*changeSource)
+	return source != nil && isMutation(method, path)
+}
+
+// isMutation - request changes security object. Searches and dry runs are POST requests too, so only POST to API root
+// creates object
+func isMutation(method, path string) bool {
+	kind, name := elasticsearch_api_client.APIObject(path)
+	if kind == "other" {
+		return false
+	}
+	switch method {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return name == ""
+	}
+	return false
+}
+
+// fetchObjectState - current elasticsearch object, nil if it doesn't exist
+func fetchObjectState(ctx context.Context, path string) []byte {
+	responseBody, httpResponse, err := defaultRequest.PrepareAndCall(ctx, path, http.MethodGet, nil, nil, nil)
+	if err != nil || httpResponse.StatusCode != http.StatusOK {
+		return nil
+	}
+	return responseBody
+}
+
+// finish - fetch state of elasticsearch object after successful mutation and record it
+func (p *pendingChange) finish(ctx context.Context, objectID string, responseResult string) {
+	if p == nil || responseResult != "Deployed" {
+		return
+	}
+	path := p.path
+	if _, name := elasticsearch_api_client.APIObject(p.path); name == "" {
+		path = p.path + "/" + objectID
+	}
+	var after []byte
+	if p.method != http.MethodDelete {
+		after = fetchObjectState(ctx, path)
+	}
+	p.finishWithState(ctx, objectID, after)
+}
+
+// finishWithState - record successful mutation with known state of elasticsearch object after it.
+// Errors are logged, change is already made and reconcile must go on
+func (p *pendingChange) finishWithState(ctx context.Context, objectID string, after []byte) {
+	if p == nil {
+		return
+	}
+	log := ctrl.LoggerFrom(ctx)
+	kind, name := elasticsearch_api_client.APIObject(p.path)
+	if name == "" {
+		name = objectID
+	}
+	operation := securityv1alpha1.ChangeOperationUpdate
+	switch {
+	case p.method == http.MethodDelete:
+		operation = securityv1alpha1.ChangeOperationDelete
+	case p.before == nil:
+		operation = securityv1alpha1.ChangeOperationCreate
+	}
+	changes := diffObjects(p.before, after)
+	if operation == securityv1alpha1.ChangeOperationUpdate && len(changes) == 0 {
+		return
+	}
+	entry := &securityv1alpha1.SecurityChangeLog{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: changeLogName(kind, name),
+			Labels:       map[string]string{changeLogKindLabel: kind},
+		},
+		Spec: securityv1alpha1.SecurityChangeLogSpec{
+			Timestamp: metav1.Now(),
+			Operation: operation,
+			Kind:      kind,
+			Name:      name,
+			Method:    p.method,
+			Path:      p.path,
+			Changes:   changes,
+			Source:    p.source,
+		},
+	}
+	if len(changes) > maxChanges {
+		entry.Spec.Changes = changes[:maxChanges]
+		entry.Spec.ChangesTruncated = true
+	}
+	if err := p.log.Client.Create(ctx, entry); err != nil {
+		log.Error(err, "Error when recording change log", "kind", kind, "operation", operation)
+	}
+}
+
+// changeLogName - prefix of SecurityChangeLog name from object kind and name
+func changeLogName(kind, name string) string {
+	name = strings.Trim(nonNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(name) > 40 {
+		name = strings.TrimRight(name[:40], "-")
+	}
+	if name == "" {
+		return kind + "-"
+	}
+	return kind + "-" + name + "-"
+}
+
+// diffObjects - changed fields of elasticsearch object. Values are compared as is, but shown with masked secrets
+func diffObjects(before, after []byte) []securityv1alpha1.SecurityChange {
+	beforeFields, beforeShown := flattenObject(before), flattenObject(elasticsearch_api_client.RedactBody(before))
+	afterFields, afterShown := flattenObject(after), flattenObject(elasticsearch_api_client.RedactBody(after))
+	paths := map[string]bool{}
+	for path := range beforeFields {
+		paths[path] = true
+	}
+	for path := range afterFields {
+		paths[path] = true
+	}
+	var changes []securityv1alpha1.SecurityChange
+	for path := range paths {
+		if beforeFields[path] == afterFields[path] {
+			continue
+		}
+		changes = append(changes, securityv1alpha1.SecurityChange{
+			Path:   path,
+			Before: truncateValue(beforeShown[path]),
+			After:  truncateValue(afterShown[path]),
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// flattenObject - JSON values by field path. Metadata of API response, like monitor _version and _seq_no, is skipped
+func flattenObject(body []byte) map[string]string {
+	fields := map[string]string{}
+	var object map[string]interface{}
+	if len(body) == 0 || json.Unmarshal(body, &object) != nil {
+		return fields
+	}
+	for key, value := range object {
+		if !strings.HasPrefix(key, "_") {
+			flattenValue(key, value, fields)
+		}
+	}
+	return fields
+}
+
+func flattenValue(path string, value interface{}, fields map[string]string) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if len(typed) == 0 {
+			fields[path] = "{}"
+		}
+		for key, item := range typed {
+			flattenValue(path+"."+key, item, fields)
+		}
+	case []interface{}:
+		if len(typed) == 0 {
+			fields[path] = "[]"
+		}
+		for i, item := range typed {
+			flattenValue(fmt.Sprintf("%v[%v]", path, i), item, fields)
+		}
+	default:
+		// Keep <redacted> readable
+		var encoded bytes.Buffer
+		encoder := json.NewEncoder(&encoded)
+		encoder.SetEscapeHTML(false)
+		_ = encoder.Encode(typed)
+		fields[path] = strings.TrimSuffix(encoded.String(), "\n")
+	}
+}
+
+func truncateValue(value string) string {
+	if len(value) > maxChangeValueLength {
+		return value[:maxChangeValueLength] + "..."
+	}
+	return value
+}
+
+// Start - delete records older than retention. Implements manager.Runnable
+func (c *ChangeLog) Start(ctx context.Context) error {
+	if c.Retention <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(changeLogCleanupPeriod)
+	defer ticker.Stop()
+	for {
+		c.cleanup(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *ChangeLog) cleanup(ctx context.Context) {
+	log := ctrl.Log.WithName("ChangeLog")
+	entries := &securityv1alpha1.SecurityChangeLogList{}
+	if err := c.Client.List(ctx, entries); err != nil {
+		log.Error(err, "Error when listing change log")
+		return
+	}
+	expired := time.Now().Add(-c.Retention)
+	deleted := 0
+	for i := range entries.Items {
+		entry := &entries.Items[i]
+		if !entry.Spec.Timestamp.Time.Before(expired) {
+			continue
+		}
+		if err := c.Client.Delete(ctx, entry); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Error when deleting expired change log", "name", entry.Name)
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		log.Info("Expired change log is deleted", "deleted", deleted, "retention", c.Retention.String())
+	}
+}
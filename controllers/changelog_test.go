@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+	elasticsearch_api_client "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch"
+)
+
+func TestDiffObjects(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   string
+		after    string
+		expected []securityv1alpha1.SecurityChange
+	}{
+		{
+			"created",
+			"",
+			`{"role":{"cluster_permissions":["cluster_monitor"]}}`,
+			[]securityv1alpha1.SecurityChange{{Path: "role.cluster_permissions[0]", After: `"cluster_monitor"`}},
+		},
+		{
+			"updated",
+			`{"role":{"cluster_permissions":["cluster_monitor"],"index_permissions":[]}}`,
+			`{"role":{"cluster_permissions":["cluster_composite_ops"],"index_permissions":[{"index_patterns":["logs-*"]}]}}`,
+			[]securityv1alpha1.SecurityChange{
+				{Path: "role.cluster_permissions[0]", Before: `"cluster_monitor"`, After: `"cluster_composite_ops"`},
+				{Path: "role.index_permissions", Before: "[]"},
+				{Path: "role.index_permissions[0].index_patterns[0]", After: `"logs-*"`},
+			},
+		},
+		{
+			"deleted",
+			`{"user":{"backend_roles":["admin"]}}`,
+			"",
+			[]securityv1alpha1.SecurityChange{{Path: "user.backend_roles[0]", Before: `"admin"`}},
+		},
+		{
+			"secret changed",
+			`{"user":{"hash":"old"}}`,
+			`{"user":{"hash":"new"}}`,
+			[]securityv1alpha1.SecurityChange{{Path: "user.hash", Before: `"` + elasticsearch_api_client.Redacted + `"`, After: `"` + elasticsearch_api_client.Redacted + `"`}},
+		},
+		{
+			"response metadata",
+			`{"_version":1,"monitor":{"enabled":true}}`,
+			`{"_version":2,"monitor":{"enabled":true}}`,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes := diffObjects([]byte(test.before), []byte(test.after))
+			if !reflect.DeepEqual(changes, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, changes)
+			}
+		})
+	}
+}
+
+func TestChangeLogName(t *testing.T) {
+	if name := changeLogName("role", "Team_A.Readers"); name != "role-team-a-readers-" {
+		t.Fatalf("unexpected name %q", name)
+	}
+	if name := changeLogName("monitor", ""); name != "monitor-" {
+		t.Fatalf("unexpected name %q", name)
+	}
+}
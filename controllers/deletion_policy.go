@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	config "github.com/aberestyak/elasticsearch-security-operator/config"
+)
+
+// EffectiveDeletionPolicy - deletion policy from spec or operator default
+func EffectiveDeletionPolicy(policy string) string {
+	if policy == "" {
+		return config.AppConfig.DefaultDeletionPolicy
+	}
+	return policy
+}
+
+// KeepsObjects - deletion policy keeps elasticsearch objects
+func KeepsObjects(policy string) bool {
+	return policy == config.DeletionPolicyOrphan || policy == config.DeletionPolicyRetain
+}
+
+// DeletesTenants - deletion policy deletes tenants with Kibana saved objects together with role
+func DeletesTenants(policy string) bool {
+	return policy == config.DeletionPolicyDelete
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	elasticsearch_api_client "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch"
+)
+
+// Event reasons
+const (
+	reasonCreated        = "Created"
+	reasonUpdated        = "Updated"
+	reasonDriftCorrected = "DriftCorrected"
+	reasonDeleted        = "Deleted"
+	reasonOrphaned       = "Orphaned"
+	reasonRejected       = "Rejected"
+	reasonFailed         = "Failed"
+	reasonInvalid        = "Invalid"
+	reasonConflict       = "Conflict"
+	reasonProtected      = "Protected"
+	// Alert acknowledgement
+	reasonAcknowledged      = "Acknowledged"
+	reasonAcknowledgeFailed = "AcknowledgeFailed"
+)
+
+// Events must stay readable in kubectl describe
+const maxEventMessageLength = 512
+
+// recordNormal - emit Normal event, if recorder is set
+func recordNormal(recorder record.EventRecorder, object runtime.Object, reason, messageFmt string, args ...interface{}) {
+	if recorder == nil {
+		return
+	}
+	recorder.Eventf(object, corev1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// recordWarning - emit Warning event with truncated message without secrets, if recorder is set
+func recordWarning(recorder record.EventRecorder, object runtime.Object, reason, message string) {
+	if recorder == nil {
+		return
+	}
+	message = elasticsearch_api_client.RedactText(message)
+	if len(message) > maxEventMessageLength {
+		message = message[:maxEventMessageLength] + "..."
+	}
+	recorder.Event(object, corev1.EventTypeWarning, reason, message)
+}
+
+// recordAPIResult - emit Normal event, if object was deployed, or Warning event with API response, if it was rejected
+func recordAPIResult(recorder record.EventRecorder, object runtime.Object, responseResult, reason, message string, responseBody []byte) {
+	if responseResult == "Deployed" {
+		recordNormal(recorder, object, reason, "%v", message)
+		return
+	}
+	recordWarning(recorder, object, reasonRejected, "Elasticsearch API rejected request: "+responseText(responseBody))
+}
+
+// updateReason - Updated, if resource spec was changed since last successful sync, or DriftCorrected otherwise
+func updateReason(generation, observedGeneration int64) string {
+	if generation == observedGeneration {
+		return reasonDriftCorrected
+	}
+	return reasonUpdated
+}
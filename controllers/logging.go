@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/aberestyak/elasticsearch-security-operator/internal/tracing"
+)
+
+// reconcileContext - put logger with request-scoped fields and reconcile span into context, so helpers log with them
+// and their requests are traced as children of reconcile. Span must be ended by caller
+func reconcileContext(ctx context.Context, base logr.Logger, controller string, req ctrl.Request) (context.Context, logr.Logger, *tracing.Span) {
+	if base == nil {
+		base = ctrl.Log.WithName("controllers").WithName(controller)
+	}
+	ctx, span := tracing.Start(ctx, controller+".Reconcile", tracing.SpanKindInternal, "controller", controller, "namespace", req.Namespace, "name", req.Name)
+	logger := base.WithValues("controller", controller, "namespace", req.Namespace, "name", req.Name, "reconcileID", string(uuid.NewUUID()))
+	if span != nil {
+		logger = logger.WithValues("traceID", span.TraceID())
+	}
+	return ctrl.LoggerInto(ctx, logger), logger, span
+}
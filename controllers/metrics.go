@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+)
+
+var (
+	driftCorrectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "elasticsearch_security_operator_drift_corrections_total",
+		Help: "Number of existing Elasticsearch objects, which differed from desired state and were updated",
+	}, []string{"kind"})
+	lastSuccessfulSyncTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "elasticsearch_security_operator_last_successful_sync_timestamp",
+		Help: "Unix time of the last successful synchronization of resource with Elasticsearch",
+	}, []string{"kind", "namespace", "name"})
+	managedObjectsDesc = prometheus.NewDesc(
+		"elasticsearch_security_operator_managed_objects",
+		"Number of resources, managed by operator, by state",
+		[]string{"kind", "state"}, nil,
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(driftCorrectionsTotal, lastSuccessfulSyncTimestamp)
+}
+
+// RecordDriftCorrection - count update of elasticsearch object, which was changed outside of operator, while spec was not
+func RecordDriftCorrection(kind string) {
+	driftCorrectionsTotal.WithLabelValues(kind).Inc()
+}
+
+// RecordSuccessfulSync - set time of successful synchronization of resource
+func RecordSuccessfulSync(kind string, object client.Object) {
+	lastSuccessfulSyncTimestamp.WithLabelValues(kind, object.GetNamespace(), object.GetName()).Set(float64(time.Now().Unix()))
+}
+
+// ForgetSync - remove synchronization time of deleted resource
+func ForgetSync(kind string, object client.Object) {
+	lastSuccessfulSyncTimestamp.DeleteLabelValues(kind, object.GetNamespace(), object.GetName())
+}
+
+// ManagedObjectsCollector - count managed resources by state from manager cache on every scrape
+type ManagedObjectsCollector struct {
+	Client client.Reader
+}
+
+// Describe implements prometheus.Collector
+func (c *ManagedObjectsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- managedObjectsDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *ManagedObjectsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.TODO()
+	counts := map[string]map[string]int{}
+	count := func(kind, state string) {
+		if state == "" {
+			state = "Pending"
+		}
+		if counts[kind] == nil {
+			counts[kind] = map[string]int{}
+		}
+		counts[kind][state]++
+	}
+	roleList := &securityv1alpha1.RoleList{}
+	if err := c.Client.List(ctx, roleList); err == nil {
+		for _, role := range roleList.Items {
+			count("role", role.Status.Status)
+		}
+	}
+	userList := &securityv1alpha1.UserList{}
+	if err := c.Client.List(ctx, userList); err == nil {
+		for _, user := range userList.Items {
+			count("user", user.Status.Status)
+		}
+	}
+	alertList := &securityv1alpha1.AlertList{}
+	if err := c.Client.List(ctx, alertList); err == nil {
+		for _, alert := range alertList.Items {
+			count("alert", alert.Status.Monitor.Status)
+		}
+	}
+	actionGroupList := &securityv1alpha1.ActionGroupList{}
+	if err := c.Client.List(ctx, actionGroupList); err == nil {
+		for _, actionGroup := range actionGroupList.Items {
+			count("actiongroup", actionGroup.Status.Status)
+		}
+	}
+	roleTemplateList := &securityv1alpha1.RoleTemplateList{}
+	if err := c.Client.List(ctx, roleTemplateList); err == nil {
+		for _, roleTemplate := range roleTemplateList.Items {
+			count("roletemplate", roleTemplate.Status.Status)
+		}
+	}
+	for kind, states := range counts {
+		for state, value := range states {
+			ch <- prometheus.MustNewConstMetric(managedObjectsDesc, prometheus.GaugeValue, float64(value), kind, state)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+	config "github.com/aberestyak/elasticsearch-security-operator/config"
+)
+
+// EffectiveName - name of elasticsearch object for namespaced custom resource.
+// Explicit name from spec overrides naming strategy
+func EffectiveName(object metav1.Object, explicitName string) string {
+	if explicitName != "" {
+		return explicitName
+	}
+	return qualifiedName(object.GetNamespace(), object.GetName())
+}
+
+// EffectiveRoleName - name of elasticsearch role and role mapping for Role
+func EffectiveRoleName(role *securityv1alpha1.Role) string {
+	return EffectiveName(role, role.Spec.Name)
+}
+
+// EffectiveUserName - name of elasticsearch internal user for User
+func EffectiveUserName(user *securityv1alpha1.User) string {
+	return EffectiveName(user, user.Spec.Name)
+}
+
+// EffectiveMonitorName - name of monitor for Alert. Monitor name is always set in spec,
+// so naming strategy only adds namespace prefix
+func EffectiveMonitorName(alert *securityv1alpha1.Alert) string {
+	return qualifiedName(alert.Namespace, alert.Spec.Name)
+}
+
+// RoleTemplateRoleName - name of elasticsearch role, created from RoleTemplate for namespace. Names of roles of
+// different namespaces must differ, so namespace is suffix of name, unless naming strategy adds it as prefix
+func RoleTemplateRoleName(roleTemplate *securityv1alpha1.RoleTemplate, namespace string) string {
+	if config.AppConfig.NamingStrategy == config.NamingStrategyNamespaceName {
+		return qualifiedName(namespace, roleTemplate.Name)
+	}
+	return roleTemplate.Name + "-" + namespace
+}
+
+func qualifiedName(namespace, name string) string {
+	if config.AppConfig.NamingStrategy == config.NamingStrategyNamespaceName {
+		return namespace + "-" + name
+	}
+	return name
+}
+
+// nameClaim - elasticsearch object name, which is requested by custom resource
+type nameClaim struct {
+	object metav1.Object
+	name   string
+	// Name is recorded in status, so object is already managed by custom resource
+	claimed bool
+}
+
+// findNameOwner - return another custom resource, which owns elasticsearch object name of passed one.
+// Resource, which already manages object, owns it. Otherwise the oldest resource wins
+func findNameOwner(self nameClaim, others []nameClaim) metav1.Object {
+	if self.claimed {
+		return nil
+	}
+	var owner metav1.Object
+	for _, other := range others {
+		if other.name != self.name || other.object.GetUID() == self.object.GetUID() || other.object.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if other.claimed {
+			return other.object
+		}
+		if isOlder(other.object, self.object) && (owner == nil || isOlder(other.object, owner)) {
+			owner = other.object
+		}
+	}
+	return owner
+}
+
+// isOlder - compare creation time, resources created at the same second are ordered by namespace and name
+func isOlder(a, b metav1.Object) bool {
+	aTime, bTime := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if !aTime.Equal(&bTime) {
+		return aTime.Before(&bTime)
+	}
+	return a.GetNamespace()+"/"+a.GetName() < b.GetNamespace()+"/"+b.GetName()
+}
+
+// nameConflict - describe resource, which owns elasticsearch object name
+func nameConflict(kind, name string, owner metav1.Object) string {
+	if owner.GetNamespace() == "" {
+		return "Elasticsearch " + kind + " " + name + " is already managed by " + owner.GetName()
+	}
+	return "Elasticsearch " + kind + " " + name + " is already managed by " + owner.GetNamespace() + "/" + owner.GetName()
+}
+
+// RoleNameClaims - names of elasticsearch roles, requested by every Role and by roles of every RoleTemplate.
+// Roles of template are known from its status, role in conflict doesn't claim name
+func RoleNameClaims(ctx context.Context, c client.Client) ([]nameClaim, error) {
+	roleList := &securityv1alpha1.RoleList{}
+	if err := c.List(ctx, roleList); err != nil {
+		return nil, errors.New("Error when listing roles: " + err.Error())
+	}
+	roleTemplateList := &securityv1alpha1.RoleTemplateList{}
+	if err := c.List(ctx, roleTemplateList); err != nil {
+		return nil, errors.New("Error when listing role templates: " + err.Error())
+	}
+	claims := make([]nameClaim, 0, len(roleList.Items))
+	for i := range roleList.Items {
+		other := &roleList.Items[i]
+		claims = append(claims, nameClaim{object: other, name: EffectiveRoleName(other), claimed: other.Status.Name == EffectiveRoleName(other)})
+	}
+	for i := range roleTemplateList.Items {
+		roleTemplate := &roleTemplateList.Items[i]
+		for _, statusRole := range roleTemplate.Status.Roles {
+			if statusRole.Status != "Conflict" {
+				claims = append(claims, nameClaim{object: roleTemplate, name: statusRole.Name, claimed: statusRole.Status == "Deployed"})
+			}
+		}
+	}
+	return claims, nil
+}
+
+// CheckRoleNameConflict - return conflict description, if elasticsearch role name is owned by another Role or RoleTemplate
+func CheckRoleNameConflict(ctx context.Context, c client.Client, role *securityv1alpha1.Role, name string) (string, error) {
+	others, err := RoleNameClaims(ctx, c)
+	if err != nil {
+		return "", err
+	}
+	if owner := findNameOwner(nameClaim{object: role, name: name, claimed: role.Status.Name == name}, others); owner != nil {
+		return nameConflict("role", name, owner), nil
+	}
+	return "", nil
+}
+
+// CheckUserNameConflict - return conflict description, if elasticsearch user name is owned by another User
+func CheckUserNameConflict(ctx context.Context, c client.Client, user *securityv1alpha1.User, name string) (string, error) {
+	userList := &securityv1alpha1.UserList{}
+	if err := c.List(ctx, userList); err != nil {
+		return "", errors.New("Error when listing users: " + err.Error())
+	}
+	others := make([]nameClaim, 0, len(userList.Items))
+	for i := range userList.Items {
+		other := &userList.Items[i]
+		others = append(others, nameClaim{object: other, name: EffectiveUserName(other), claimed: other.Status.Name == EffectiveUserName(other)})
+	}
+	if owner := findNameOwner(nameClaim{object: user, name: name, claimed: user.Status.Name == name}, others); owner != nil {
+		return nameConflict("user", name, owner), nil
+	}
+	return "", nil
+}
+
+// CheckMonitorNameConflict - return conflict description, if monitor name is owned by another Alert
+func CheckMonitorNameConflict(ctx context.Context, c client.Client, alert *securityv1alpha1.Alert, name string) (string, error) {
+	alertList := &securityv1alpha1.AlertList{}
+	if err := c.List(ctx, alertList); err != nil {
+		return "", errors.New("Error when listing alerts: " + err.Error())
+	}
+	others := make([]nameClaim, 0, len(alertList.Items))
+	for i := range alertList.Items {
+		other := &alertList.Items[i]
+		others = append(others, nameClaim{object: other, name: EffectiveMonitorName(other), claimed: isMonitorClaimed(other, EffectiveMonitorName(other))})
+	}
+	if owner := findNameOwner(nameClaim{object: alert, name: name, claimed: isMonitorClaimed(alert, name)}, others); owner != nil {
+		return nameConflict("monitor", name, owner), nil
+	}
+	return "", nil
+}
+
+func isMonitorClaimed(alert *securityv1alpha1.Alert, name string) bool {
+	return alert.Status.Monitor.ID != "" && alert.Status.Monitor.Name == name
+}
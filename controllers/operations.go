@@ -1,16 +1,23 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
 
 	config "github.com/aberestyak/elasticsearch-security-operator/config"
 	elasticsearch_api_client "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch"
-	log "github.com/sirupsen/logrus"
+	"github.com/aberestyak/elasticsearch-security-operator/internal/logger"
 )
 
+// Time limit of single request to elasticsearch
+const requestTimeout = 30 * time.Second
+
 var (
 	defaultRequest = elasticsearch_api_client.APIClient{
 		Cfg: &elasticsearch_api_client.Configuration{
@@ -20,26 +27,29 @@ var (
 				UserName: config.AppConfig.ElasticsearchUsername,
 				Password: config.AppConfig.ElasticsearchPassword,
 			},
-			HTTPClient: &http.Client{},
+			HTTPClient: &http.Client{Timeout: requestTimeout},
 		},
 	}
-	apiClientWrapperLogger = log.WithFields(log.Fields{
-		"component": "ApiClientWrapper",
-	})
 )
 
 // MakeAPIRequest - make request to endpoint
-func MakeAPIRequest(method string, path string, jsonBody []byte) (ObjectID string, Status string, ResponseBody []byte, Error error) {
-	responseBody, httpResponse, err := defaultRequest.PrepareAndCall(path, method, jsonBody, nil, url.Values{})
+func MakeAPIRequest(ctx context.Context, method string, path string, jsonBody []byte) (ObjectID string, Status string, ResponseBody []byte, Error error) {
+	requestLogger := ctrl.LoggerFrom(ctx).WithName("ApiClientWrapper").WithValues("method", method, "path", path)
+	change := startChange(ctx, method, path)
+	responseBody, httpResponse, err := defaultRequest.PrepareAndCall(ctx, path, method, jsonBody, nil, url.Values{})
 	if err != nil {
-		apiClientWrapperLogger.Errorf("Error when creating new object: %v", err.Error())
+		requestLogger.Error(err, "Error when making request")
 		return "", "", nil, err
 	}
-	RequesDebugtLogger := log.WithFields(log.Fields{
-		"component": "RequestDebug",
-	})
-	RequesDebugtLogger.Debugf("Method: %v. Path: %v. Body: %v. ResponseCode: %v. ResponseBody: %v", method, path, string(jsonBody), httpResponse.StatusCode, string(responseBody))
-	return GetResponseObjectID(responseBody), GetResponseStatus(httpResponse), responseBody, nil
+	requestLogger.V(logger.Debug).Info("Request completed", "status", httpResponse.StatusCode, "body", responseText(jsonBody), "responseBody", responseText(responseBody))
+	objectID, responseResult := GetResponseObjectID(ctx, responseBody), GetResponseStatus(httpResponse)
+	change.finish(ctx, objectID, responseResult)
+	return objectID, responseResult, responseBody, nil
+}
+
+// responseText - response body with masked secrets, safe for logs, events and status
+func responseText(responseBody []byte) string {
+	return string(elasticsearch_api_client.RedactBody(responseBody))
 }
 
 // GetResponseStatus - return Error or Deployed based on http status code
@@ -51,10 +61,10 @@ func GetResponseStatus(response *http.Response) string {
 }
 
 // GetResponseObjectID - get object ID, if exists
-func GetResponseObjectID(responseBody []byte) string {
+func GetResponseObjectID(ctx context.Context, responseBody []byte) string {
 	var result map[string]string
 	if err := json.Unmarshal(responseBody, &result); err != nil {
-		apiClientWrapperLogger.Tracef("Can't unmarshal response body: %v", err)
+		ctrl.LoggerFrom(ctx).V(logger.Trace).Info("Can't unmarshal response body", "error", err.Error())
 	}
 	return result["_id"]
 }
@@ -68,8 +78,8 @@ func SanitizeQuery(query json.RawMessage) json.RawMessage {
 }
 
 // GetExistingObject - make GET request to get existing elsticsearch object
-func GetExistingObject(path, ID string) (bool, []byte, error) {
-	_, responseResult, responseBody, err := MakeAPIRequest("GET", path+"/"+ID, nil)
+func GetExistingObject(ctx context.Context, path, ID string) (bool, []byte, error) {
+	_, responseResult, responseBody, err := MakeAPIRequest(ctx, "GET", path+"/"+ID, nil)
 	if err != nil {
 		return false, nil, err
 	}
@@ -78,3 +88,13 @@ func GetExistingObject(path, ID string) (bool, []byte, error) {
 	}
 	return true, responseBody, nil
 }
+
+// containsString - check if slice contains passed string
+func containsString(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	alerts "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/alerts"
+)
+
+const (
+	// Ownership tag, appended to description of roles
+	ownershipTagPrefix = "[managed by elasticsearch-security-operator, uid "
+	// Ownership key in attributes of internal users and ui_metadata of monitors
+	ownershipKey = "elasticsearch-security-operator/uid"
+	// Key of desired spec hash in ui_metadata of monitors
+	monitorSpecHashKey = "elasticsearch-security-operator/spec-hash"
+	// Type of status condition, which is true, when elasticsearch object or its name belongs to another resource
+	conditionConflict = "Conflict"
+)
+
+var ownershipTagRegexp = regexp.MustCompile(`\s*\[managed by elasticsearch-security-operator, uid ([^\]]+)\]$`)
+
+// OwnedDescription - append ownership tag of custom resource to description
+func OwnedDescription(description string, uid types.UID) string {
+	tag := ownershipTagPrefix + string(uid) + "]"
+	if description == "" {
+		return tag
+	}
+	return description + " " + tag
+}
+
+// DescriptionOwner - return uid of custom resource from ownership tag in description or empty string
+func DescriptionOwner(description string) string {
+	match := ownershipTagRegexp.FindStringSubmatch(description)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// MonitorOwner - return uid of custom resource from monitor ui_metadata or empty string
+func MonitorOwner(monitor *alerts.AlertAPISpec) string {
+	if monitor == nil {
+		return ""
+	}
+	owner, _ := monitor.UIMetadata[ownershipKey].(string)
+	return owner
+}
+
+// CheckOwnership - describe conflict, if existing elasticsearch object is not managed by custom resource.
+// Untagged object is considered managed, if it was deployed by custom resource before ownership tracking was introduced
+func CheckOwnership(kind, name, owner string, uid types.UID, adopt, wasDeployed bool) string {
+	switch {
+	case owner == string(uid), adopt:
+		return ""
+	case owner == "" && wasDeployed:
+		return ""
+	case owner == "":
+		return "Elasticsearch " + kind + " " + name + " already exists and is not managed by operator. Set spec.adopt to take it over"
+	}
+	return "Elasticsearch " + kind + " " + name + " is managed by another resource with uid " + owner + ". Set spec.adopt to take it over"
+}
+
+// setConflictCondition - set Conflict condition by result of synchronization: true for conflict, false for deployed object.
+// Other results don't show, whether object is owned, so condition is kept
+func setConflictCondition(conditions *[]metav1.Condition, generation int64, responseResult, message string) {
+	switch responseResult {
+	case "Conflict":
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:               conditionConflict,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: generation,
+			Reason:             "Conflict",
+			Message:            message,
+		})
+	case "Deployed":
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:               conditionConflict,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: generation,
+			Reason:             "Deployed",
+			Message:            "Elasticsearch object is managed by resource",
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package controllers
+
+// defaultKnownPermissions - transport actions of Elasticsearch OSS 7.10 and Open Distro plugins, used unless
+// knownPermissions is configured. They are fallback for actions, which aren't used by built-in roles and action groups
+var defaultKnownPermissions = []string{
+	// Cluster
+	"cluster:admin/component_template/delete",
+	"cluster:admin/component_template/get",
+	"cluster:admin/component_template/put",
+	"cluster:admin/indices/dangling/delete",
+	"cluster:admin/indices/dangling/find",
+	"cluster:admin/indices/dangling/import",
+	"cluster:admin/indices/dangling/list",
+	"cluster:admin/ingest/pipeline/delete",
+	"cluster:admin/ingest/pipeline/get",
+	"cluster:admin/ingest/pipeline/put",
+	"cluster:admin/ingest/pipeline/simulate",
+	"cluster:admin/nodes/reload_secure_settings",
+	"cluster:admin/reindex/rethrottle",
+	"cluster:admin/repository/_cleanup",
+	"cluster:admin/repository/delete",
+	"cluster:admin/repository/get",
+	"cluster:admin/repository/put",
+	"cluster:admin/repository/verify",
+	"cluster:admin/reroute",
+	"cluster:admin/script/delete",
+	"cluster:admin/script/get",
+	"cluster:admin/script/put",
+	"cluster:admin/script_context/get",
+	"cluster:admin/script_language/get",
+	"cluster:admin/settings/update",
+	"cluster:admin/snapshot/clone",
+	"cluster:admin/snapshot/create",
+	"cluster:admin/snapshot/delete",
+	"cluster:admin/snapshot/get",
+	"cluster:admin/snapshot/restore",
+	"cluster:admin/snapshot/status",
+	"cluster:admin/tasks/cancel",
+	"cluster:admin/voting_config/add_exclusions",
+	"cluster:admin/voting_config/clear_exclusions",
+	"cluster:monitor/allocation/explain",
+	"cluster:monitor/health",
+	"cluster:monitor/main",
+	"cluster:monitor/nodes/hot_threads",
+	"cluster:monitor/nodes/info",
+	"cluster:monitor/nodes/liveness",
+	"cluster:monitor/nodes/stats",
+	"cluster:monitor/nodes/usage",
+	"cluster:monitor/remote/info",
+	"cluster:monitor/state",
+	"cluster:monitor/stats",
+	"cluster:monitor/task",
+	"cluster:monitor/task/get",
+	"cluster:monitor/tasks/list",
+	// Indices
+	"indices:admin/aliases",
+	"indices:admin/aliases/get",
+	"indices:admin/analyze",
+	"indices:admin/auto_create",
+	"indices:admin/block/add",
+	"indices:admin/cache/clear",
+	"indices:admin/close",
+	"indices:admin/create",
+	"indices:admin/data_stream/create",
+	"indices:admin/data_stream/delete",
+	"indices:admin/data_stream/get",
+	"indices:admin/delete",
+	"indices:admin/exists",
+	"indices:admin/flush",
+	"indices:admin/forcemerge",
+	"indices:admin/get",
+	"indices:admin/index_template/delete",
+	"indices:admin/index_template/get",
+	"indices:admin/index_template/put",
+	"indices:admin/index_template/simulate",
+	"indices:admin/index_template/simulate_index",
+	"indices:admin/mapping/put",
+	"indices:admin/mappings/fields/get",
+	"indices:admin/mappings/get",
+	"indices:admin/open",
+	"indices:admin/refresh",
+	"indices:admin/resize",
+	"indices:admin/resolve/index",
+	"indices:admin/rollover",
+	"indices:admin/seq_no/global_checkpoint_sync",
+	"indices:admin/settings/update",
+	"indices:admin/shards/search_shards",
+	"indices:admin/shrink",
+	"indices:admin/synced_flush",
+	"indices:admin/template/delete",
+	"indices:admin/template/get",
+	"indices:admin/template/put",
+	"indices:admin/types/exists",
+	"indices:admin/upgrade",
+	"indices:admin/validate/query",
+	"indices:data/read/explain",
+	"indices:data/read/field_caps",
+	"indices:data/read/get",
+	"indices:data/read/mget",
+	"indices:data/read/msearch",
+	"indices:data/read/msearch/template",
+	"indices:data/read/mtv",
+	"indices:data/read/scroll",
+	"indices:data/read/scroll/clear",
+	"indices:data/read/search",
+	"indices:data/read/search/template",
+	"indices:data/read/tv",
+	"indices:data/write/bulk",
+	"indices:data/write/delete",
+	"indices:data/write/delete/byquery",
+	"indices:data/write/index",
+	"indices:data/write/reindex",
+	"indices:data/write/update",
+	"indices:data/write/update/byquery",
+	"indices:monitor/data_stream/stats",
+	"indices:monitor/recovery",
+	"indices:monitor/segments",
+	"indices:monitor/settings/get",
+	"indices:monitor/shard_stores",
+	"indices:monitor/stats",
+	"indices:monitor/upgrade",
+	// Open Distro Security
+	"cluster:admin/opendistro_security/config/update",
+	// Open Distro Alerting
+	"cluster:admin/opendistro/alerting/alerts/ack",
+	"cluster:admin/opendistro/alerting/alerts/get",
+	"cluster:admin/opendistro/alerting/destination/delete",
+	"cluster:admin/opendistro/alerting/destination/email_account/delete",
+	"cluster:admin/opendistro/alerting/destination/email_account/get",
+	"cluster:admin/opendistro/alerting/destination/email_account/search",
+	"cluster:admin/opendistro/alerting/destination/email_account/write",
+	"cluster:admin/opendistro/alerting/destination/email_group/delete",
+	"cluster:admin/opendistro/alerting/destination/email_group/get",
+	"cluster:admin/opendistro/alerting/destination/email_group/search",
+	"cluster:admin/opendistro/alerting/destination/email_group/write",
+	"cluster:admin/opendistro/alerting/destination/get",
+	"cluster:admin/opendistro/alerting/destination/write",
+	"cluster:admin/opendistro/alerting/monitor/delete",
+	"cluster:admin/opendistro/alerting/monitor/execute",
+	"cluster:admin/opendistro/alerting/monitor/get",
+	"cluster:admin/opendistro/alerting/monitor/search",
+	"cluster:admin/opendistro/alerting/monitor/write",
+	// Open Distro Anomaly Detection
+	"cluster:admin/opendistro/ad/detector/delete",
+	"cluster:admin/opendistro/ad/detector/info",
+	"cluster:admin/opendistro/ad/detector/jobmanagement",
+	"cluster:admin/opendistro/ad/detector/preview",
+	"cluster:admin/opendistro/ad/detector/run",
+	"cluster:admin/opendistro/ad/detector/search",
+	"cluster:admin/opendistro/ad/detector/stats",
+	"cluster:admin/opendistro/ad/detector/write",
+	"cluster:admin/opendistro/ad/detectors/get",
+	"cluster:admin/opendistro/ad/result/search",
+	"cluster:admin/opendistro/ad/tasks/search",
+	// Open Distro Index Management
+	"cluster:admin/opendistro/ism/managedindex/add",
+	"cluster:admin/opendistro/ism/managedindex/change",
+	"cluster:admin/opendistro/ism/managedindex/explain",
+	"cluster:admin/opendistro/ism/managedindex/remove",
+	"cluster:admin/opendistro/ism/managedindex/retry",
+	"cluster:admin/opendistro/ism/policy/delete",
+	"cluster:admin/opendistro/ism/policy/get",
+	"cluster:admin/opendistro/ism/policy/search",
+	"cluster:admin/opendistro/ism/policy/write",
+	"cluster:admin/opendistro/rollup/delete",
+	"cluster:admin/opendistro/rollup/explain",
+	"cluster:admin/opendistro/rollup/get",
+	"cluster:admin/opendistro/rollup/index",
+	"cluster:admin/opendistro/rollup/search",
+	"cluster:admin/opendistro/rollup/start",
+	"cluster:admin/opendistro/rollup/stop",
+	// Open Distro Reports
+	"cluster:admin/opendistro/reports/definition/create",
+	"cluster:admin/opendistro/reports/definition/delete",
+	"cluster:admin/opendistro/reports/definition/get",
+	"cluster:admin/opendistro/reports/definition/list",
+	"cluster:admin/opendistro/reports/definition/on_demand",
+	"cluster:admin/opendistro/reports/definition/update",
+	"cluster:admin/opendistro/reports/instance/get",
+	"cluster:admin/opendistro/reports/instance/list",
+	"cluster:admin/opendistro/reports/menu/download",
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	config "github.com/aberestyak/elasticsearch-security-operator/config"
+)
+
+// securityObjectFlags - flags of built-in objects, returned by security API
+type securityObjectFlags struct {
+	Reserved bool `json:"reserved"`
+	Static   bool `json:"static"`
+	Hidden   bool `json:"hidden"`
+}
+
+// CheckProtected - describe protection, if existing security object is reserved, static or hidden
+// and is not allowed in config as <kind>/<name>
+func CheckProtected(kind, name string, existingObject []byte) (string, error) {
+	objects := make(map[string]securityObjectFlags, 1)
+	if err := json.Unmarshal(existingObject, &objects); err != nil {
+		return "", errors.New("Error when unmarshaling existing " + kind + ": " + err.Error())
+	}
+	flags := objects[name]
+	var reasons []string
+	if flags.Reserved {
+		reasons = append(reasons, "reserved")
+	}
+	if flags.Static {
+		reasons = append(reasons, "static")
+	}
+	if flags.Hidden {
+		reasons = append(reasons, "hidden")
+	}
+	if len(reasons) == 0 || containsString(config.AppConfig.ProtectedObjectsAllowlist, kind+"/"+name) {
+		return "", nil
+	}
+	return "Elasticsearch " + kind + " " + name + " is " + strings.Join(reasons, ", ") + " and can't be managed by operator", nil
+}
+
+// GetProtection - get existing security object and describe its protection
+func GetProtection(ctx context.Context, kind, path, name string) (string, error) {
+	exists, existingObject, err := GetExistingObject(ctx, path, name)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", nil
+	}
+	return CheckProtected(kind, name, existingObject)
+}
+
+// DeleteUnprotectedObject - make DELETE request to delete security object, unless it is protected
+func DeleteUnprotectedObject(ctx context.Context, kind, path, name string) error {
+	protection, err := GetProtection(ctx, kind, path, name)
+	if err != nil {
+		return errors.New("Error when checking " + kind + " protection: " + err.Error())
+	}
+	if protection != "" {
+		ctrl.LoggerFrom(ctx).Info("Skip deletion", "reason", protection)
+		return nil
+	}
+	_, _, _, err = MakeAPIRequest(ctx, "DELETE", path+"/"+name, nil)
+	if err != nil {
+		return errors.New("Error when deleting " + kind + ": " + err.Error())
+	}
+	return nil
+}
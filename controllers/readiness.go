@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	config "github.com/aberestyak/elasticsearch-security-operator/config"
+	"github.com/aberestyak/elasticsearch-security-operator/internal/logger"
+)
+
+// permissionProbe - request, which must be allowed for operator user
+type permissionProbe struct {
+	method string
+	path   func() string
+	body   []byte
+}
+
+var (
+	readinessLogger   = ctrl.Log.WithName("Readiness")
+	rolesProbe        = permissionProbe{method: "GET", path: func() string { return config.AppConfig.ElasticsearchRoleAPIPath }}
+	roleMappingsProbe = permissionProbe{method: "GET", path: func() string { return config.AppConfig.ElasticsearchRoleMappingAPIPath }}
+	tenantsProbe      = permissionProbe{method: "GET", path: func() string { return config.AppConfig.ElasticsearchTenantAPIPath }}
+	actionGroupsProbe = permissionProbe{method: "GET", path: func() string { return config.AppConfig.ElasticsearchActionGroupAPIPath }}
+	usersProbe        = permissionProbe{method: "GET", path: func() string { return config.AppConfig.ElasticsearchUserAPIPath }}
+	monitorsProbe     = permissionProbe{method: "POST", path: func() string { return config.AppConfig.ElasticsearchAlertAPIPath + "/_search" }, body: []byte(`{"size":0}`)}
+	// controllerPermissions - requests, required by each controller
+	controllerPermissions = map[string][]permissionProbe{
+		"Alert":        {monitorsProbe},
+		"ActionGroup":  {actionGroupsProbe},
+		"Role":         {rolesProbe, roleMappingsProbe, tenantsProbe, actionGroupsProbe},
+		"RoleTemplate": {rolesProbe, roleMappingsProbe, tenantsProbe},
+		"User":         {usersProbe},
+	}
+)
+
+// Time limit of single readiness check
+const readinessCheckTimeout = 10 * time.Second
+
+var elasticsearchReady = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "elasticsearch_security_operator_elasticsearch_ready",
+	Help: "1, if Elasticsearch is reachable, operator user is authenticated and has permissions, required by controllers, 0 otherwise",
+})
+
+func init() {
+	metrics.Registry.MustRegister(elasticsearchReady)
+}
+
+// ReadinessCheck - check, that elasticsearch is reachable, operator user is authenticated and has permissions, required by enabled controllers.
+// Result is cached for TTL, so probes don't flood elasticsearch
+type ReadinessCheck struct {
+	Controllers []string
+	TTL         time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	lastErr   error
+}
+
+// authInfo - response of authinfo API
+type authInfo struct {
+	UserName string   `json:"user_name"`
+	Roles    []string `json:"roles"`
+}
+
+// Check - healthz.Checker, which returns cached result of the last check. Check is bounded by timeout, so probes
+// don't hang on unresponsive elasticsearch
+func (c *ReadinessCheck) Check(req *http.Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.TTL {
+		return c.lastErr
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), readinessCheckTimeout)
+	defer cancel()
+	err := c.check(ctx)
+	switch {
+	case err != nil:
+		elasticsearchReady.Set(0)
+		readinessLogger.Error(err, "Elasticsearch is not ready")
+	case c.lastErr != nil:
+		elasticsearchReady.Set(1)
+		readinessLogger.Info("Elasticsearch is ready")
+	default:
+		elasticsearchReady.Set(1)
+	}
+	c.lastErr = err
+	c.checkedAt = time.Now()
+	return err
+}
+
+func (c *ReadinessCheck) check(ctx context.Context) error {
+	statusCode, responseBody, err := callProbe(ctx, "GET", config.AppConfig.ElasticsearchAuthInfoAPIPath, nil)
+	if err != nil {
+		return errors.New("Elasticsearch endpoint is unreachable: " + err.Error())
+	}
+	if statusCode == http.StatusUnauthorized {
+		return errors.New("Authentication failed for user " + config.AppConfig.ElasticsearchUsername)
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("Error when getting authentication info: %v %v", statusCode, responseText(responseBody))
+	}
+	var info authInfo
+	if err := json.Unmarshal(responseBody, &info); err != nil {
+		return errors.New("Error when unmarshaling authentication info: " + err.Error())
+	}
+	readinessLogger.V(logger.Debug).Info("Authenticated", "user", info.UserName, "roles", info.Roles)
+
+	var denied []string
+	checked := map[string]bool{}
+	for _, controller := range c.Controllers {
+		for _, probe := range controllerPermissions[controller] {
+			path := probe.path()
+			if checked[probe.method+" "+path] {
+				continue
+			}
+			checked[probe.method+" "+path] = true
+			statusCode, responseBody, err := callProbe(ctx, probe.method, path, probe.body)
+			if err != nil {
+				return errors.New("Elasticsearch endpoint is unreachable: " + err.Error())
+			}
+			switch {
+			case statusCode < 300:
+			// There is no alerting config index until the first monitor is created
+			case statusCode == http.StatusNotFound && strings.Contains(string(responseBody), "index_not_found_exception"):
+			case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+				denied = append(denied, fmt.Sprintf("%v: %v %v", controller, probe.method, path))
+			default:
+				return fmt.Errorf("Error when checking %v permissions: %v %v returned %v", controller, probe.method, path, statusCode)
+			}
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("User %v has no permissions: %v", info.UserName, strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// callProbe - make request and return http status code. Probes run outside of reconcile and aren't traced
+func callProbe(ctx context.Context, method, path string, body []byte) (int, []byte, error) {
+	responseBody, httpResponse, err := defaultRequest.PrepareAndCall(ctx, path, method, body, nil, url.Values{})
+	if err != nil {
+		return 0, nil, err
+	}
+	return httpResponse.StatusCode, responseBody, nil
+}
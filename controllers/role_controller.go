@@ -20,15 +20,22 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"reflect"
 
 	"github.com/go-logr/logr"
-	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
 	config "github.com/aberestyak/elasticsearch-security-operator/config"
@@ -37,38 +44,41 @@ import (
 
 const roleFinalizer = "role.security.rshbdev.ru/finalizer"
 
-var roleControllerLogger = log.WithFields(log.Fields{
-	"component": "RoleController",
-})
-
 // RoleReconciler reconciles a Role object
 type RoleReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+	ChangeLog *ChangeLog
 }
 
 //+kubebuilder:rbac:groups=security.rshbdev.ru,resources=roles,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=security.rshbdev.ru,resources=roles/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=security.rshbdev.ru,resources=roles/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile main reconcile loop
 func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, log, span := reconcileContext(ctx, r.Log, "Role", req)
+	defer span.End()
 	desiredRole := &securityv1alpha1.Role{}
 	var err = r.Get(ctx, req.NamespacedName, desiredRole)
 	if err != nil {
 		if kerrors.IsNotFound(err) {
-			roleControllerLogger.Info("Resource was deleted")
+			log.Info("Resource was deleted")
 			return ctrl.Result{}, nil
 		}
-		roleControllerLogger.Errorf("Error while reading CR Role: %v", err)
+		log.Error(err, "Error while reading CR Role")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	ctx = r.ChangeLog.WithSource(ctx, desiredRole)
 	// Call finalyzer to clean up
 	isdesiredRoleToBeDeleted := desiredRole.GetDeletionTimestamp() != nil
 	if isdesiredRoleToBeDeleted {
 		if controllerutil.ContainsFinalizer(desiredRole, roleFinalizer) {
-			if err := r.FinalizeRole(desiredRole); err != nil {
+			if err := r.FinalizeRole(ctx, desiredRole); err != nil {
 				return ctrl.Result{}, err
 			}
 			controllerutil.RemoveFinalizer(desiredRole, roleFinalizer)
@@ -88,115 +98,208 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		}
 	}
 
-	// Map model to RoleAPISpec
-	roleAPIObject, err := MapRoleAPIObject(desiredRole)
+	// Steps are reported only when pipeline runs
+	desiredRole.Status.Steps = nil
+
+	// Refuse to manage elasticsearch role, which belongs to Role from another namespace
+	roleName := EffectiveRoleName(desiredRole)
+	log = log.WithValues("role", roleName)
+	ctx = ctrl.LoggerInto(ctx, log)
+	conflict, err := CheckRoleNameConflict(ctx, r.Client, desiredRole, roleName)
 	if err != nil {
-		roleControllerLogger.Errorf("Error when mapping models : %v", err)
+		log.Error(err, "Error when checking role name conflicts")
 		return ctrl.Result{}, err
 	}
+	if conflict != "" {
+		log.Info("Role conflicts with another role", "reason", conflict)
+		recordWarning(r.Recorder, desiredRole, reasonConflict, conflict)
+		if err := SetRoleStatus(ctx, r, desiredRole, "Conflict", []byte(conflict)); err != nil {
+			log.Error(err, "Error when setting role status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	// Status keeps name of managed role, until role is deployed with new name
+	previousName := desiredRole.Status.Name
 
+	// Map model to RoleAPISpec, evaluating templates with namespace variables
+	templateData, err := GetRoleTemplateData(ctx, r.Client, desiredRole)
+	if err != nil {
+		log.Error(err, "Error when getting role template variables")
+		return ctrl.Result{}, err
+	}
+	roleAPIObject, err := MapRoleAPIObject(desiredRole, templateData)
+	if err != nil {
+		log.Error(err, "Error when mapping models")
+		recordWarning(r.Recorder, desiredRole, reasonInvalid, err.Error())
+		if err := SetRoleStatus(ctx, r, desiredRole, "Invalid", []byte(err.Error())); err != nil {
+			log.Error(err, "Error when setting role status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Check allowed actions against action groups and permissions, known to the cluster
+	invalidActions, err := ValidateRoleActions(ctx, desiredRole)
+	if err != nil {
+		log.Info("Can't validate allowed actions", "error", err.Error())
+	} else if invalidActions != "" {
+		log.Info("Role is invalid", "reason", invalidActions)
+		recordWarning(r.Recorder, desiredRole, reasonInvalid, invalidActions)
+		if err := SetRoleStatus(ctx, r, desiredRole, "Invalid", []byte(invalidActions)); err != nil {
+			log.Error(err, "Error when setting role status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Tag role, so it is not taken over by another resource or operator
+	roleAPIObject.Description = OwnedDescription(roleAPIObject.Description, desiredRole.UID)
 	apiRoleJSON, err := json.Marshal(roleAPIObject)
 	if err != nil {
-		roleControllerLogger.Errorf("Error when marshaling role object: %v", err)
+		log.Error(err, "Error when marshaling role object")
 		return ctrl.Result{}, err
 	}
-	roleExists, existingRoleSpec, err := GetExistingObject(config.AppConfig.ElasticsearchRoleAPIPath, desiredRole.Name)
+	roleExists, existingRoleSpec, err := GetExistingObject(ctx, config.AppConfig.ElasticsearchRoleAPIPath, roleName)
 	if err != nil {
-		roleControllerLogger.Errorf("Error when checking role existence: %v", err.Error())
+		log.Error(err, "Error when checking role existence")
 		return ctrl.Result{}, err
 	}
-	if !roleExists {
-		// Create
-		if err := CreateOrUpdateRole(r, desiredRole, apiRoleJSON); err != nil {
-			roleControllerLogger.Errorf("Error when creating new role: %v", err.Error())
-		}
-		roleControllerLogger.Infof("Created new role: %v. Status: %v", desiredRole.Name, desiredRole.Status.Status)
-	} else {
-		// Update
-		// Trying to get existing role
-		existingRole := make(map[string]roles.RoleAPISpec, 1)
-		if err := json.Unmarshal(existingRoleSpec, &existingRole); err != nil {
-			roleControllerLogger.Errorf("Error when unmarshaling existing role: %v", err.Error())
+	var existingRole *roles.RoleAPISpec
+	if roleExists {
+		existingRoles := make(map[string]roles.RoleAPISpec, 1)
+		if err := json.Unmarshal(existingRoleSpec, &existingRoles); err != nil {
+			log.Error(err, "Error when unmarshaling existing role")
 			return ctrl.Result{}, err
 		}
-		// Compare existing and desired role spec
-		if !reflect.DeepEqual(existingRole[desiredRole.Name], roleAPIObject) {
-			if err := CreateOrUpdateRole(r, desiredRole, apiRoleJSON); err != nil {
-				roleControllerLogger.Errorf("Error when updating role: %v", err.Error())
-			}
-			roleControllerLogger.Infof("Updated role: %v. Status: %v", desiredRole.Name, desiredRole.Status.Status)
+		// Refuse to modify built-in role
+		protection, err := CheckProtected("role", roleName, existingRoleSpec)
+		if err != nil {
+			log.Error(err, "Error when checking role protection")
+			return ctrl.Result{}, err
 		}
-		// Create or update roleMapping, no matter is this create or update operation and update role status
-		if err := CreateRoleMapping(desiredRole); err != nil {
-			if err := SetRoleStatus(r, desiredRole, "Error", []byte(err.Error())); err != nil {
-				roleControllerLogger.Errorf("Error when setting role status: %v", err.Error())
+		if protection != "" {
+			log.Info("Role targets protected role", "reason", protection)
+			recordWarning(r.Recorder, desiredRole, reasonProtected, protection)
+			if err := SetRoleStatus(ctx, r, desiredRole, "Protected", []byte(protection)); err != nil {
+				log.Error(err, "Error when setting role status")
+				return ctrl.Result{}, err
 			}
+			return ctrl.Result{}, nil
 		}
-		// Create tenant, no matter is this create or update operation and update role status
-		if err := CreateTenant(desiredRole); err != nil {
-			if err := SetRoleStatus(r, desiredRole, "Error", []byte(err.Error())); err != nil {
-				roleControllerLogger.Errorf("Error when setting role status: %v", err.Error())
+		// Refuse to overwrite role, created by hand or by another operator
+		owner := DescriptionOwner(existingRoles[roleName].Description)
+		wasDeployed := desiredRole.Status.Status == "Deployed" && (previousName == "" || previousName == roleName)
+		if conflict := CheckOwnership("role", roleName, owner, desiredRole.UID, desiredRole.Spec.Adopt, wasDeployed); conflict != "" {
+			log.Info("Role conflicts with existing role", "reason", conflict)
+			recordWarning(r.Recorder, desiredRole, reasonConflict, conflict)
+			if err := SetRoleStatus(ctx, r, desiredRole, "Conflict", []byte(conflict)); err != nil {
+				log.Error(err, "Error when setting role status")
+				return ctrl.Result{}, err
 			}
+			return ctrl.Result{}, nil
 		}
+		existing := existingRoles[roleName]
+		existingRole = &existing
+	}
 
+	if previousName == "" {
+		desiredRole.Status.Name = roleName
+	}
+	// Tenants must exist before role grants access to them, and mapping must reference existing role
+	steps := []roleStep{
+		{roleStepTenants, func() error { return CreateTenant(ctx, desiredRole, roleAPIObject.TenantPermissions) }},
+		{roleStepRole, func() error {
+			return ApplyRole(ctx, r, desiredRole, roleName, existingRole, roleAPIObject, apiRoleJSON)
+		}},
+		{roleStepRoleMapping, func() error { return CreateRoleMapping(ctx, roleName, desiredRole) }},
+	}
+	// Naming strategy or explicit name was changed: role with previous name is released, when role with new name works
+	if previousName != "" && previousName != roleName {
+		steps = append(steps, roleStep{roleStepRename, func() error { return ReleaseRenamedRole(ctx, r, desiredRole, previousName, roleName) }})
+	}
+	if err := RunRoleSteps(ctx, r, desiredRole, steps); err != nil {
+		log.Error(err, "Error when synchronizing role")
+		return ctrl.Result{}, err
+	}
+	if desiredRole.Status.Status == "Deployed" {
+		RecordSuccessfulSync("role", desiredRole)
 	}
 	return ctrl.Result{}, nil
 }
 
-// MapRoleAPIObject - map CRD model to API
-func MapRoleAPIObject(role *securityv1alpha1.Role) (*roles.RoleAPISpec, error) {
+// MapRoleAPIObject - render role templates and map CRD model to API
+func MapRoleAPIObject(role *securityv1alpha1.Role, templateData *RoleTemplateData) (*roles.RoleAPISpec, error) {
+	renderedSpec, err := RenderRoleSpec(role.Spec, templateData)
+	if err != nil {
+		return nil, err
+	}
 	var roleAPI roles.RoleAPISpec
-	buf, _ := json.Marshal(role.Spec)
+	buf, _ := json.Marshal(renderedSpec)
 	if err := json.Unmarshal(buf, &roleAPI); err != nil {
 		return nil, err
 	}
 	return &roleAPI, nil
 }
 
-// SetRoleStatus - parse http response code, set status and update CR
-func SetRoleStatus(r *RoleReconciler, role *securityv1alpha1.Role, responseResult string, responseBody []byte) error {
+// SetRoleStatus - parse http response code, set status and update CR, if status was changed.
+// Steps are kept, they are set by role pipeline and are cleared before checks, which stop reconcile
+func SetRoleStatus(ctx context.Context, r *RoleReconciler, role *securityv1alpha1.Role, responseResult string, responseBody []byte) error {
+	observedGeneration := role.Status.ObservedGeneration
+	if responseResult == "Deployed" {
+		observedGeneration = role.Generation
+	}
+	oldStatus := role.Status
 	role.Status = securityv1alpha1.RoleStatus{
-		Status: responseResult,
+		Status:             responseResult,
+		Name:               role.Status.Name,
+		ObservedGeneration: observedGeneration,
+		Steps:              role.Status.Steps,
+		Conditions:         append([]metav1.Condition(nil), role.Status.Conditions...),
 		Error: func(response string, responseBody []byte) string {
-			if response == "Error" {
-				return string(responseBody)
+			if response != "Deployed" {
+				return responseText(responseBody)
 			}
 			return ""
 		}(responseResult, responseBody),
 	}
-	if err := r.Client.Status().Update(context.TODO(), role); err != nil {
-		return errors.New("Error when setting status: " + err.Error())
-	}
-	return nil
-}
-
-// CreateOrUpdateRole - make PUT request to create or update Role
-func CreateOrUpdateRole(r *RoleReconciler, role *securityv1alpha1.Role, jsonRole []byte) error {
-	_, responseResult, responseBody, err := MakeAPIRequest("PUT", config.AppConfig.ElasticsearchRoleAPIPath+"/"+role.Name, jsonRole)
-	if err != nil {
-		return errors.New("Error when creating new role: " + err.Error())
+	setConflictCondition(&role.Status.Conditions, role.Generation, responseResult, role.Status.Error)
+	if equality.Semantic.DeepEqual(oldStatus, role.Status) {
+		return nil
 	}
-	if err := SetRoleStatus(r, role, responseResult, responseBody); err != nil {
-		return err
+	if err := r.Client.Status().Update(ctx, role); err != nil {
+		return errors.New("Error when setting status: " + err.Error())
 	}
-	roleControllerLogger.Infof("Updated role: %v", role.Name)
 	return nil
 }
 
-// CreateTenant - make PUT request to create or update Tenant
-func CreateTenant(role *securityv1alpha1.Role) error {
+// CreateTenant - make PUT request to create or update every tenant from rendered tenant permissions
+func CreateTenant(ctx context.Context, role *securityv1alpha1.Role, tenantPermissions []roles.TenantPermissions) error {
+	log := ctrl.LoggerFrom(ctx)
 	// Must provide description
 	description := map[string]string{"description": role.Name}
 	descriptionJSON, _ := json.Marshal(description)
-	for _, tenantPattern := range role.Spec.TenantPermissions {
+	for _, tenantPattern := range tenantPermissions {
 		for _, tenant := range tenantPattern.TenantPatterns {
 			// Don't update default global tenant
 			if tenant != "global_tenant" {
-				_, _, _, err := MakeAPIRequest("PUT", config.AppConfig.ElasticsearchTenantAPIPath+"/"+tenant, descriptionJSON)
+				protection, err := GetProtection(ctx, "tenant", config.AppConfig.ElasticsearchTenantAPIPath, tenant)
+				if err != nil {
+					return errors.New("Error when checking tenant protection: " + err.Error())
+				}
+				// Protected tenant already exists, so permissions still work
+				if protection != "" {
+					log.Info("Skip tenant update", "tenant", tenant, "reason", protection)
+					continue
+				}
+				_, responseResult, responseBody, err := MakeAPIRequest(ctx, "PUT", config.AppConfig.ElasticsearchTenantAPIPath+"/"+tenant, descriptionJSON)
 				if err != nil {
 					return errors.New("Error when updating tenant: " + err.Error())
 				}
-				roleControllerLogger.Infof("Updated tenant: %v", tenant)
+				if responseResult != "Deployed" {
+					return errors.New("Error when updating tenant " + tenant + ": " + responseText(responseBody))
+				}
+				log.Info("Updated tenant", "tenant", tenant)
 			}
 		}
 	}
@@ -204,12 +307,11 @@ func CreateTenant(role *securityv1alpha1.Role) error {
 }
 
 // DeleteTenant - make DELETE request to delete tenant
-func DeleteTenant(tenant string) error {
+func DeleteTenant(ctx context.Context, tenant string) error {
 	// Don't delete default global tenant
 	if tenant != "global_tenant" {
-		_, _, _, err := MakeAPIRequest("DELETE", config.AppConfig.ElasticsearchTenantAPIPath+"/"+tenant, nil)
-		if err != nil {
-			roleControllerLogger.Errorf("Error when deleting tenant: %v", err.Error())
+		if err := DeleteUnprotectedObject(ctx, "tenant", config.AppConfig.ElasticsearchTenantAPIPath, tenant); err != nil {
+			ctrl.LoggerFrom(ctx).Error(err, "Error when deleting tenant", "tenant", tenant)
 			return err
 		}
 	}
@@ -220,26 +322,141 @@ func DeleteTenant(tenant string) error {
 func (r *RoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&securityv1alpha1.Role{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: config.AppConfig.MaxConcurrentReconciles}).
+		Watches(&source.Kind{Type: &securityv1alpha1.ActionGroup{}}, handler.EnqueueRequestsFromMapFunc(r.rolesForActionGroup)).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.rolesForNamespace)).
 		Complete(r)
 }
 
+// rolesForNamespace - enqueue roles of namespace, so templates are rendered with actual namespace labels
+func (r *RoleReconciler) rolesForNamespace(namespace client.Object) []reconcile.Request {
+	roleList := &securityv1alpha1.RoleList{}
+	if err := r.List(context.TODO(), roleList, client.InNamespace(namespace.GetName())); err != nil {
+		r.Log.Error(err, "Error when listing roles in namespace", "namespace", namespace.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(roleList.Items))
+	for _, role := range roleList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: role.Namespace, Name: role.Name}})
+	}
+	return requests
+}
+
+// rolesForActionGroup - enqueue roles, which reference changed action group in allowed actions
+func (r *RoleReconciler) rolesForActionGroup(actionGroup client.Object) []reconcile.Request {
+	roleList := &securityv1alpha1.RoleList{}
+	if err := r.List(context.TODO(), roleList); err != nil {
+		r.Log.Error(err, "Error when listing roles for action group", "actionGroup", actionGroup.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, role := range roleList.Items {
+		if roleReferencesAction(&role, actionGroup.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: role.Namespace, Name: role.Name}})
+		}
+	}
+	return requests
+}
+
+func roleReferencesAction(role *securityv1alpha1.Role, action string) bool {
+	if containsString(role.Spec.ClusterPermissons, action) {
+		return true
+	}
+	for _, indexPermission := range role.Spec.IndexPermissions {
+		if containsString(indexPermission.AllowedActions, action) {
+			return true
+		}
+	}
+	for _, tenantPermission := range role.Spec.TenantPermissions {
+		if containsString(tenantPermission.AllowedActions, action) {
+			return true
+		}
+	}
+	return false
+}
+
 // FinalizeRole delete role
-func (r *RoleReconciler) FinalizeRole(role *securityv1alpha1.Role) error {
-	for _, tenantPattern := range role.Spec.TenantPermissions {
+func (r *RoleReconciler) FinalizeRole(ctx context.Context, role *securityv1alpha1.Role) error {
+	log := ctrl.LoggerFrom(ctx)
+	// Elasticsearch role belongs to another Role or is built-in. Role with previous name is still managed
+	if (role.Status.Status == "Conflict" || role.Status.Status == "Protected") && (role.Status.Name == "" || role.Status.Name == EffectiveRoleName(role)) {
+		log.Info("Role doesn't manage elasticsearch role, skip deletion")
+		return nil
+	}
+	deletionPolicy := EffectiveDeletionPolicy(role.Spec.DeletionPolicy)
+	if KeepsObjects(deletionPolicy) {
+		log.Info("Keep elasticsearch role, role mapping and tenants", "deletionPolicy", deletionPolicy)
+		recordNormal(r.Recorder, role, reasonOrphaned, "Kept elasticsearch role, role mapping and tenants with %v deletion policy", deletionPolicy)
+		ForgetSync("role", role)
+		return nil
+	}
+	templateData, err := GetRoleTemplateData(ctx, r.Client, role)
+	if err != nil {
+		log.Error(err, "Error when finalyzing role")
+		return err
+	}
+	var tenantPermissions []roles.TenantPermissions
+	if !DeletesTenants(deletionPolicy) {
+		log.Info("Keep tenants", "deletionPolicy", deletionPolicy)
+	} else if roleAPIObject, err := MapRoleAPIObject(role, templateData); err != nil {
+		// Role with invalid templates never had tenants created
+		log.Info("Skip tenants deletion", "error", err.Error())
+	} else {
+		tenantPermissions = roleAPIObject.TenantPermissions
+	}
+	for _, tenantPattern := range tenantPermissions {
 		for _, tenant := range tenantPattern.TenantPatterns {
-			if err := DeleteTenant(tenant); err != nil {
-				roleControllerLogger.Errorf("Error when finalyzing role: %v", err.Error())
+			if err := DeleteTenant(ctx, tenant); err != nil {
+				log.Error(err, "Error when finalyzing role")
 				return err
 			}
-			roleControllerLogger.Infof("Updated tenant: %v", tenant)
+			log.Info("Deleted tenant", "tenant", tenant)
 		}
 	}
 
-	_, _, _, err := MakeAPIRequest("DELETE", config.AppConfig.ElasticsearchRoleAPIPath+"/"+role.Name, nil)
-	if err != nil {
-		roleControllerLogger.Errorf("Error when finalyzing role: %v", err.Error())
+	// Roles, deployed before naming strategy was introduced, have no name in status
+	roleName := role.Status.Name
+	if roleName == "" {
+		roleName = EffectiveRoleName(role)
+	}
+	if err := DeleteRoleMapping(ctx, roleName); err != nil {
+		log.Error(err, "Error when finalyzing role")
+		recordWarning(r.Recorder, role, reasonFailed, err.Error())
+		return err
+	}
+	if err := DeleteRole(ctx, roleName); err != nil {
+		log.Error(err, "Error when finalyzing role")
+		recordWarning(r.Recorder, role, reasonFailed, err.Error())
 		return err
 	}
-	roleControllerLogger.Infof("Successfully finalized role: %v", role.Name)
+	ForgetSync("role", role)
+	recordNormal(r.Recorder, role, reasonDeleted, "Deleted elasticsearch role and role mapping %v with %v deletion policy", roleName, deletionPolicy)
+	log.Info("Successfully finalized role")
 	return nil
 }
+
+// ReleaseRenamedRole - delete role mapping and role with previous name according to deletion policy
+// and record new name in status
+func ReleaseRenamedRole(ctx context.Context, r *RoleReconciler, role *securityv1alpha1.Role, previousName, name string) error {
+	log := ctrl.LoggerFrom(ctx).WithValues("previousRole", previousName)
+	if deletionPolicy := EffectiveDeletionPolicy(role.Spec.DeletionPolicy); KeepsObjects(deletionPolicy) {
+		log.Info("Keep elasticsearch role with previous name", "deletionPolicy", deletionPolicy)
+		recordNormal(r.Recorder, role, reasonOrphaned, "Kept elasticsearch role %v with %v deletion policy", previousName, deletionPolicy)
+	} else {
+		if err := DeleteRoleMapping(ctx, previousName); err != nil {
+			return err
+		}
+		if err := DeleteRole(ctx, previousName); err != nil {
+			return err
+		}
+		log.Info("Deleted elasticsearch role with previous name")
+		recordNormal(r.Recorder, role, reasonDeleted, "Deleted elasticsearch role %v, renamed to %v", previousName, name)
+	}
+	role.Status.Name = name
+	return nil
+}
+
+// DeleteRole - make DELETE request to delete role, unless it is protected
+func DeleteRole(ctx context.Context, name string) error {
+	return DeleteUnprotectedObject(ctx, "role", config.AppConfig.ElasticsearchRoleAPIPath, name)
+}
@@ -1,23 +1,21 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"reflect"
+
+	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
 	"github.com/aberestyak/elasticsearch-security-operator/config"
 	rolemappings "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/rolemappings"
-	log "github.com/sirupsen/logrus"
 )
 
-var roleMappingLogger = log.WithFields(log.Fields{
-	"component": "RoleMapping",
-})
-
-// CreateRoleMapping - create/update RoleMapping object, based on passed role
-func CreateRoleMapping(role *v1alpha1.Role) error {
-	roleMappingExists, existingRoleMappingSpec, err := GetExistingObject(config.AppConfig.ElasticsearchRoleMappingAPIPath, role.Name)
+// CreateRoleMapping - create/update RoleMapping object with passed name, based on passed role
+func CreateRoleMapping(ctx context.Context, name string, role *v1alpha1.Role) error {
+	log := ctrl.LoggerFrom(ctx).WithValues("roleMapping", name)
+	roleMappingExists, existingRoleMappingSpec, err := GetExistingObject(ctx, config.AppConfig.ElasticsearchRoleMappingAPIPath, name)
 	if err != nil {
 		return err
 	}
@@ -27,22 +25,30 @@ func CreateRoleMapping(role *v1alpha1.Role) error {
 
 	if !roleMappingExists {
 		// Create new roleMapping
-		if err := UpdateRoleMapping(role.Name, apiRoleMappingJSON); err != nil {
+		if err := UpdateRoleMapping(ctx, name, apiRoleMappingJSON); err != nil {
 			return err
 		}
-		roleMappingLogger.Infof("Created roleMapping: %v.", role.Name)
+		log.Info("Created roleMapping")
 	} else {
+		// Refuse to modify built-in roleMapping
+		protection, err := CheckProtected("rolemapping", name, existingRoleMappingSpec)
+		if err != nil {
+			return err
+		}
+		if protection != "" {
+			return errors.New(protection)
+		}
 		// Update existing if need
 		existingRoleMapping := make(map[string]rolemappings.RoleMappingAPISpec, 1)
 		if err := json.Unmarshal(existingRoleMappingSpec, &existingRoleMapping); err != nil {
 			return err
 		}
-		if !reflect.DeepEqual(existingRoleMapping[role.Name], apiRoleMappingObject) {
-			if err := UpdateRoleMapping(role.Name, apiRoleMappingJSON); err != nil {
-				roleMappingLogger.Errorf("Error when updating roleMapping: %v", err.Error())
+		if !existingRoleMapping[name].Equal(*apiRoleMappingObject) {
+			if err := UpdateRoleMapping(ctx, name, apiRoleMappingJSON); err != nil {
+				log.Error(err, "Error when updating roleMapping")
 				return err
 			}
-			roleMappingLogger.Infof("Updated roleMapping: %v.", role.Name)
+			log.Info("Updated roleMapping")
 		}
 	}
 	return nil
@@ -56,13 +62,18 @@ func MapAPIRoleMappingObject(role *v1alpha1.Role) *rolemappings.RoleMappingAPISp
 }
 
 // UpdateRoleMapping - make request to create or update RoleMapping for "parent" role
-func UpdateRoleMapping(name string, jsonRoleMapping []byte) error {
-	_, responseResult, responseBody, err := MakeAPIRequest("PUT", config.AppConfig.ElasticsearchRoleMappingAPIPath+"/"+name, jsonRoleMapping)
+func UpdateRoleMapping(ctx context.Context, name string, jsonRoleMapping []byte) error {
+	responseResult, responseBody, err := PutObject(ctx, config.AppConfig.ElasticsearchRoleMappingAPIPath, name, jsonRoleMapping)
 	if err != nil {
 		return errors.New("Error when creating new role:" + err.Error())
 	}
 	if responseResult != "Error" {
 		return nil
 	}
-	return errors.New("Error when updating roleMapping" + name + "." + string(responseBody))
+	return errors.New("Error when updating roleMapping " + name + ": " + responseText(responseBody))
+}
+
+// DeleteRoleMapping - make DELETE request to delete RoleMapping of role, unless it is protected
+func DeleteRoleMapping(ctx context.Context, name string) error {
+	return DeleteUnprotectedObject(ctx, "rolemapping", config.AppConfig.ElasticsearchRoleMappingAPIPath, name)
 }
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+	config "github.com/aberestyak/elasticsearch-security-operator/config"
+	roles "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/roles"
+)
+
+// Steps of role synchronization
+const (
+	roleStepTenants     = "Tenants"
+	roleStepRole        = "Role"
+	roleStepRoleMapping = "RoleMapping"
+	roleStepRename      = "Rename"
+)
+
+// States of role synchronization step
+const (
+	stepStateDeployed = "Deployed"
+	stepStateError    = "Error"
+	stepStateSkipped  = "Skipped"
+)
+
+// roleStep - step of role synchronization. Steps are idempotent, so every reconcile runs all of them
+type roleStep struct {
+	name string
+	run  func() error
+}
+
+// runRoleSteps - run steps in order until the first failure. Returns result of every step and error of failed step
+func runRoleSteps(ctx context.Context, steps []roleStep) ([]securityv1alpha1.RoleStepStatus, string, error) {
+	log := ctrl.LoggerFrom(ctx)
+	var failed error
+	var failedStep string
+	statuses := make([]securityv1alpha1.RoleStepStatus, 0, len(steps))
+	for _, step := range steps {
+		if failed != nil {
+			statuses = append(statuses, securityv1alpha1.RoleStepStatus{Name: step.name, State: stepStateSkipped})
+			continue
+		}
+		if err := step.run(); err != nil {
+			failed, failedStep = err, step.name
+			log.Error(err, "Role synchronization step failed", "step", step.name)
+			statuses = append(statuses, securityv1alpha1.RoleStepStatus{Name: step.name, State: stepStateError, Error: responseText([]byte(err.Error()))})
+			continue
+		}
+		statuses = append(statuses, securityv1alpha1.RoleStepStatus{Name: step.name, State: stepStateDeployed})
+	}
+	return statuses, failedStep, failed
+}
+
+// RunRoleSteps - run steps in order until the first failure and set role status with result of every step.
+// Failed step is reported in events and status and its error is returned, so role is requeued
+func RunRoleSteps(ctx context.Context, r *RoleReconciler, role *securityv1alpha1.Role, steps []roleStep) error {
+	statuses, failedStep, failed := runRoleSteps(ctx, steps)
+	role.Status.Steps = statuses
+	if failed != nil {
+		// Role step reports its failures itself, rejected role is Rejected event
+		if failedStep != roleStepRole {
+			recordWarning(r.Recorder, role, reasonFailed, failed.Error())
+		}
+		if err := SetRoleStatus(ctx, r, role, "Error", []byte(failed.Error())); err != nil {
+			return err
+		}
+		return failed
+	}
+	return SetRoleStatus(ctx, r, role, "Deployed", nil)
+}
+
+// ApplyRole - make PUT request to create role with passed name or update it, if it differs from existing one, and emit event.
+// Existing role is nil, if role doesn't exist
+func ApplyRole(ctx context.Context, r *RoleReconciler, role *securityv1alpha1.Role, name string, existingRole, roleAPIObject *roles.RoleAPISpec, jsonRole []byte) error {
+	reason := reasonCreated
+	if existingRole != nil {
+		if existingRole.Equal(*roleAPIObject) {
+			return nil
+		}
+		reason = updateReason(role.Generation, role.Status.ObservedGeneration)
+		if reason == reasonDriftCorrected {
+			RecordDriftCorrection("role")
+		}
+	}
+	responseResult, responseBody, err := PutObject(ctx, config.AppConfig.ElasticsearchRoleAPIPath, name, jsonRole)
+	if err != nil {
+		recordWarning(r.Recorder, role, reasonFailed, "Error when updating role: "+err.Error())
+		return errors.New("Error when updating role: " + err.Error())
+	}
+	recordAPIResult(r.Recorder, role, responseResult, reason, reason+" elasticsearch role "+name, responseBody)
+	if responseResult != "Deployed" {
+		return errors.New("Error when updating role: " + responseText(responseBody))
+	}
+	ctrl.LoggerFrom(ctx).Info("Updated elasticsearch role", "reason", reason)
+	return nil
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+)
+
+// RoleTemplateData - variables, available in role index patterns, DLS and tenant patterns
+type RoleTemplateData struct {
+	Namespace string
+	Name      string
+	Labels    map[string]string
+}
+
+// GetRoleTemplateData - collect template variables for role from its namespace.
+// Labels are empty, if namespace is already deleted
+func GetRoleTemplateData(ctx context.Context, c client.Client, role *securityv1alpha1.Role) (*RoleTemplateData, error) {
+	data := &RoleTemplateData{
+		Namespace: role.Namespace,
+		Name:      role.Name,
+		Labels:    map[string]string{},
+	}
+	namespace := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: role.Namespace}, namespace); err != nil {
+		if kerrors.IsNotFound(err) {
+			return data, nil
+		}
+		return nil, errors.New("Error when getting role namespace: " + err.Error())
+	}
+	for key, value := range namespace.Labels {
+		data.Labels[key] = value
+	}
+	return data, nil
+}
+
+// RenderRoleSpec - evaluate templates in index patterns, DLS and tenant patterns of role spec
+func RenderRoleSpec(spec securityv1alpha1.RoleSpec, data *RoleTemplateData) (*securityv1alpha1.RoleSpec, error) {
+	rendered := spec.DeepCopy()
+	var err error
+	for i := range rendered.IndexPermissions {
+		indexPermission := &rendered.IndexPermissions[i]
+		if indexPermission.IndexPatterns, err = renderRoleTemplates(indexPermission.IndexPatterns, data); err != nil {
+			return nil, errors.New("Error when rendering index patterns: " + err.Error())
+		}
+		if indexPermission.DLS, err = renderRoleTemplate(indexPermission.DLS, data); err != nil {
+			return nil, errors.New("Error when rendering DLS: " + err.Error())
+		}
+	}
+	for i := range rendered.TenantPermissions {
+		tenantPermission := &rendered.TenantPermissions[i]
+		if tenantPermission.TenantPatterns, err = renderRoleTemplates(tenantPermission.TenantPatterns, data); err != nil {
+			return nil, errors.New("Error when rendering tenant patterns: " + err.Error())
+		}
+	}
+	return rendered, nil
+}
+
+func renderRoleTemplates(values []string, data *RoleTemplateData) ([]string, error) {
+	for i, value := range values {
+		renderedValue, err := renderRoleTemplate(value, data)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = renderedValue
+	}
+	return values, nil
+}
+
+func renderRoleTemplate(value string, data *RoleTemplateData) (string, error) {
+	// Plain values are passed as is
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	// Unknown labels must not silently produce patterns like "logs-<no value>-*"
+	tmpl, err := template.New("role").Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,473 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+	config "github.com/aberestyak/elasticsearch-security-operator/config"
+	elasticsearch_api_client "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch"
+	roles "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/roles"
+)
+
+const roleTemplateFinalizer = "roletemplate.security.rshbdev.ru/finalizer"
+
+// RoleTemplateReconciler reconciles a RoleTemplate object
+type RoleTemplateReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+	ChangeLog *ChangeLog
+}
+
+//+kubebuilder:rbac:groups=security.rshbdev.ru,resources=roletemplates,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=security.rshbdev.ru,resources=roletemplates/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=security.rshbdev.ru,resources=roletemplates/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile main reconcile loop
+func (r *RoleTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, log, span := reconcileContext(ctx, r.Log, "RoleTemplate", req)
+	defer span.End()
+	roleTemplate := &securityv1alpha1.RoleTemplate{}
+	var err = r.Get(ctx, req.NamespacedName, roleTemplate)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			log.Info("Resource was deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Error while reading CR RoleTemplate")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	ctx = r.ChangeLog.WithSource(ctx, roleTemplate)
+	// Call finalyzer to clean up
+	if roleTemplate.GetDeletionTimestamp() != nil {
+		if controllerutil.ContainsFinalizer(roleTemplate, roleTemplateFinalizer) {
+			if err := r.FinalizeRoleTemplate(ctx, roleTemplate); err != nil {
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(roleTemplate, roleTemplateFinalizer)
+			err := r.Update(ctx, roleTemplate)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+	// Add finalizer for this CR
+	if !controllerutil.ContainsFinalizer(roleTemplate, roleTemplateFinalizer) {
+		controllerutil.AddFinalizer(roleTemplate, roleTemplateFinalizer)
+		err = r.Update(ctx, roleTemplate)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Check allowed actions once, they are the same for every namespace
+	invalidActions, err := ValidateRoleActions(ctx, &securityv1alpha1.Role{Spec: roleTemplate.Spec.Role})
+	if err != nil {
+		log.Info("Can't validate allowed actions", "error", err.Error())
+	} else if invalidActions != "" {
+		log.Info("Role template is invalid", "reason", invalidActions)
+		recordWarning(r.Recorder, roleTemplate, reasonInvalid, invalidActions)
+		return ctrl.Result{}, r.SetRoleTemplateStatus(ctx, roleTemplate, "Invalid", invalidActions, roleTemplate.Status.Roles)
+	}
+
+	namespaces, err := r.GetMatchingNamespaces(ctx, roleTemplate)
+	if err != nil {
+		log.Error(err, "Error when getting namespaces")
+		recordWarning(r.Recorder, roleTemplate, reasonInvalid, err.Error())
+		return ctrl.Result{}, r.SetRoleTemplateStatus(ctx, roleTemplate, "Invalid", err.Error(), roleTemplate.Status.Roles)
+	}
+
+	deletionPolicy := EffectiveDeletionPolicy(roleTemplate.Spec.Role.DeletionPolicy)
+	previousRoles := make(map[string]securityv1alpha1.StatusTemplateRole, len(roleTemplate.Status.Roles))
+	for _, statusRole := range roleTemplate.Status.Roles {
+		previousRoles[statusRole.Name] = statusRole
+	}
+	// Role names of template must not be used by Role or another template
+	nameClaims, err := RoleNameClaims(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "Error when checking role name conflicts")
+		return ctrl.Result{}, err
+	}
+	var statusRoles []securityv1alpha1.StatusTemplateRole
+	var failedRoles []string
+	for _, namespace := range namespaces {
+		previousRole, hasPreviousRole := previousRoles[RoleTemplateRoleName(roleTemplate, namespace.Name)]
+		statusRole := ApplyTemplateRole(ctx, roleTemplate, &namespace, hasPreviousRole && previousRole.Status == "Deployed", nameClaims)
+		if hasPreviousRole {
+			// Tenants of role, which failed to render or apply, are unknown, so the last known ones are kept to clean them up later
+			if statusRole.Status == "Invalid" || statusRole.Status == "Error" {
+				for _, tenant := range previousRole.Tenants {
+					if !containsString(statusRole.Tenants, tenant) {
+						statusRole.Tenants = append(statusRole.Tenants, tenant)
+					}
+				}
+			}
+			// Tenants, which are not rendered anymore, are removed
+			if statusRole.Status == "Deployed" && DeletesTenants(deletionPolicy) {
+				for _, tenant := range previousRole.Tenants {
+					if !containsString(statusRole.Tenants, tenant) {
+						if err := DeleteTenant(ctx, tenant); err != nil {
+							statusRole.Status, statusRole.Error = "Error", err.Error()
+							statusRole.Tenants = append(statusRole.Tenants, tenant)
+						}
+					}
+				}
+			}
+			delete(previousRoles, statusRole.Name)
+		}
+		r.recordTemplateRoleEvent(roleTemplate, previousRole, hasPreviousRole, statusRole)
+		if statusRole.Status != "Deployed" {
+			failedRoles = append(failedRoles, statusRole.Name)
+		}
+		statusRoles = append(statusRoles, statusRole)
+	}
+	// Namespace was deleted or doesn't match selector anymore
+	for _, previousRole := range previousRoles {
+		// Role was never managed by template
+		if previousRole.Status == "Conflict" {
+			continue
+		}
+		if err := DeleteTemplateRole(ctx, r.Recorder, roleTemplate, previousRole, deletionPolicy); err != nil {
+			log.Error(err, "Error when deleting role", "role", previousRole.Name)
+			recordWarning(r.Recorder, roleTemplate, reasonFailed, "Error when deleting role "+previousRole.Name+": "+err.Error())
+			previousRole.Status, previousRole.Error = "Error", "Error when deleting role: "+err.Error()
+			failedRoles = append(failedRoles, previousRole.Name)
+			statusRoles = append(statusRoles, previousRole)
+		}
+	}
+
+	// Failed roles are retried with backoff
+	if len(failedRoles) > 0 {
+		failed := errors.New("Failed roles: " + strings.Join(failedRoles, ", "))
+		if err := r.SetRoleTemplateStatus(ctx, roleTemplate, "Error", failed.Error(), statusRoles); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, failed
+	}
+	RecordSuccessfulSync("roletemplate", roleTemplate)
+	return ctrl.Result{}, r.SetRoleTemplateStatus(ctx, roleTemplate, "Deployed", "", statusRoles)
+}
+
+// GetMatchingNamespaces - list active namespaces, matching role template selector
+func (r *RoleTemplateReconciler) GetMatchingNamespaces(ctx context.Context, roleTemplate *securityv1alpha1.RoleTemplate) ([]corev1.Namespace, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&roleTemplate.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, errors.New("Invalid namespace selector: " + err.Error())
+	}
+	namespaceList := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaceList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.New("Error when listing namespaces: " + err.Error())
+	}
+	var namespaces []corev1.Namespace
+	for _, namespace := range namespaceList.Items {
+		// Roles of terminating namespace are removed
+		if namespace.GetDeletionTimestamp() == nil {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces, nil
+}
+
+// ApplyTemplateRole - create or update role, role mapping and tenants of role template for namespace.
+// Role is not applied, if its name is claimed by another resource
+func ApplyTemplateRole(ctx context.Context, roleTemplate *securityv1alpha1.RoleTemplate, namespace *corev1.Namespace, wasDeployed bool, nameClaims []nameClaim) securityv1alpha1.StatusTemplateRole {
+	role := &securityv1alpha1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      RoleTemplateRoleName(roleTemplate, namespace.Name),
+			Namespace: namespace.Name,
+		},
+		Spec: *roleTemplate.Spec.Role.DeepCopy(),
+	}
+	ctx = ctrl.LoggerInto(ctx, ctrl.LoggerFrom(ctx).WithValues("role", role.Name))
+	statusRole := securityv1alpha1.StatusTemplateRole{
+		Namespace: namespace.Name,
+		Name:      role.Name,
+	}
+	templateData := &RoleTemplateData{
+		Namespace: namespace.Name,
+		Name:      role.Name,
+		Labels:    map[string]string{},
+	}
+	for key, value := range namespace.Labels {
+		templateData.Labels[key] = value
+	}
+	if owner := findNameOwner(nameClaim{object: roleTemplate, name: role.Name, claimed: wasDeployed}, nameClaims); owner != nil {
+		statusRole.Status, statusRole.Error = "Conflict", nameConflict("role", role.Name, owner)
+		return statusRole
+	}
+	roleAPIObject, err := MapRoleAPIObject(role, templateData)
+	if err != nil {
+		statusRole.Status, statusRole.Error = "Invalid", err.Error()
+		return statusRole
+	}
+	for _, tenantPattern := range roleAPIObject.TenantPermissions {
+		for _, tenant := range tenantPattern.TenantPatterns {
+			if tenant != "global_tenant" && !containsString(statusRole.Tenants, tenant) {
+				statusRole.Tenants = append(statusRole.Tenants, tenant)
+			}
+		}
+	}
+	// Tag role, so it is not taken over by another resource or operator
+	roleAPIObject.Description = OwnedDescription(roleAPIObject.Description, roleTemplate.UID)
+	existingRole, conflict, err := CheckTemplateRole(ctx, role.Name, roleTemplate.UID, roleTemplate.Spec.Role.Adopt, wasDeployed)
+	if err != nil {
+		statusRole.Status, statusRole.Error = "Error", err.Error()
+		return statusRole
+	}
+	if conflict != "" {
+		statusRole.Status, statusRole.Error, statusRole.Tenants = "Conflict", conflict, nil
+		return statusRole
+	}
+	// Tenants must exist before role grants access to them, and mapping must reference existing role
+	steps := []roleStep{
+		{roleStepTenants, func() error { return CreateTenant(ctx, role, roleAPIObject.TenantPermissions) }},
+		{roleStepRole, func() error {
+			// Role of unchanged template differs from desired one, if it was changed outside of operator
+			isDrift := wasDeployed && updateReason(roleTemplate.Generation, roleTemplate.Status.ObservedGeneration) == reasonDriftCorrected
+			return UpdateTemplateRole(ctx, role.Name, existingRole, roleAPIObject, isDrift)
+		}},
+		{roleStepRoleMapping, func() error { return CreateRoleMapping(ctx, role.Name, role) }},
+	}
+	statusRole.Steps, _, err = runRoleSteps(ctx, steps)
+	if err != nil {
+		statusRole.Status, statusRole.Error = "Error", err.Error()
+		return statusRole
+	}
+	statusRole.Status = "Deployed"
+	return statusRole
+}
+
+// CheckTemplateRole - get existing role, created from template. Returns conflict description,
+// if existing role is protected or is not managed by template. Existing role is nil, if role doesn't exist
+func CheckTemplateRole(ctx context.Context, name string, uid types.UID, adopt, wasDeployed bool) (*roles.RoleAPISpec, string, error) {
+	roleExists, existingRoleSpec, err := GetExistingObject(ctx, config.AppConfig.ElasticsearchRoleAPIPath, name)
+	if err != nil {
+		return nil, "", errors.New("Error when checking role existence: " + err.Error())
+	}
+	if !roleExists {
+		return nil, "", nil
+	}
+	existingRoles := make(map[string]roles.RoleAPISpec, 1)
+	if err := json.Unmarshal(existingRoleSpec, &existingRoles); err != nil {
+		return nil, "", errors.New("Error when unmarshaling existing role: " + err.Error())
+	}
+	protection, err := CheckProtected("role", name, existingRoleSpec)
+	if err != nil {
+		return nil, "", err
+	}
+	if protection != "" {
+		return nil, protection, nil
+	}
+	if conflict := CheckOwnership("role", name, DescriptionOwner(existingRoles[name].Description), uid, adopt, wasDeployed); conflict != "" {
+		return nil, conflict, nil
+	}
+	existingRole := existingRoles[name]
+	return &existingRole, "", nil
+}
+
+// UpdateTemplateRole - make PUT request to create role or update it, if it differs from existing one.
+// Existing role is nil, if role doesn't exist. Update of drifted role is counted as drift correction
+func UpdateTemplateRole(ctx context.Context, name string, existingRole, roleAPIObject *roles.RoleAPISpec, isDrift bool) error {
+	if existingRole != nil {
+		if existingRole.Equal(*roleAPIObject) {
+			return nil
+		}
+		if isDrift {
+			RecordDriftCorrection("role")
+		}
+	}
+	apiRoleJSON, err := json.Marshal(roleAPIObject)
+	if err != nil {
+		return errors.New("Error when marshaling role object: " + err.Error())
+	}
+	responseResult, responseBody, err := PutObject(ctx, config.AppConfig.ElasticsearchRoleAPIPath, name, apiRoleJSON)
+	if err != nil {
+		return errors.New("Error when updating role: " + err.Error())
+	}
+	if responseResult != "Deployed" {
+		return errors.New("Error when updating role: " + responseText(responseBody))
+	}
+	ctrl.LoggerFrom(ctx).Info("Updated elasticsearch role")
+	return nil
+}
+
+// DeleteTemplateRole - delete tenants, role mapping and role, created from template, according to deletion policy.
+// Objects are deleted only if role is tagged by template, or role, deployed by template, was deleted outside of operator
+func DeleteTemplateRole(ctx context.Context, recorder record.EventRecorder, roleTemplate *securityv1alpha1.RoleTemplate, statusRole securityv1alpha1.StatusTemplateRole, deletionPolicy string) error {
+	log := ctrl.LoggerFrom(ctx).WithValues("role", statusRole.Name, "roleNamespace", statusRole.Namespace)
+	if KeepsObjects(deletionPolicy) {
+		log.Info("Keep role", "deletionPolicy", deletionPolicy)
+		recordNormal(recorder, roleTemplate, reasonOrphaned, "Kept role %v with %v deletion policy", statusRole.Name, deletionPolicy)
+		return nil
+	}
+	roleExists, existingRoleSpec, err := GetExistingObject(ctx, config.AppConfig.ElasticsearchRoleAPIPath, statusRole.Name)
+	if err != nil {
+		return errors.New("Error when checking role existence: " + err.Error())
+	}
+	if roleExists {
+		existingRoles := make(map[string]roles.RoleAPISpec, 1)
+		if err := json.Unmarshal(existingRoleSpec, &existingRoles); err != nil {
+			return errors.New("Error when unmarshaling existing role: " + err.Error())
+		}
+		if owner := DescriptionOwner(existingRoles[statusRole.Name].Description); owner != string(roleTemplate.UID) {
+			log.Info("Role is not managed by template, skip deletion", "owner", owner)
+			return nil
+		}
+	} else if statusRole.Status != "Deployed" {
+		log.Info("Role was not deployed by template, skip deletion")
+		return nil
+	}
+	if DeletesTenants(deletionPolicy) {
+		for _, tenant := range statusRole.Tenants {
+			if err := DeleteTenant(ctx, tenant); err != nil {
+				return err
+			}
+		}
+	}
+	if err := DeleteRoleMapping(ctx, statusRole.Name); err != nil {
+		return err
+	}
+	if err := DeleteRole(ctx, statusRole.Name); err != nil {
+		return err
+	}
+	log.Info("Deleted role")
+	recordNormal(recorder, roleTemplate, reasonDeleted, "Deleted role %v of namespace %v", statusRole.Name, statusRole.Namespace)
+	return nil
+}
+
+// recordTemplateRoleEvent - record event, when role is deployed after spec change or state of role changes
+func (r *RoleTemplateReconciler) recordTemplateRoleEvent(roleTemplate *securityv1alpha1.RoleTemplate, previousRole securityv1alpha1.StatusTemplateRole, hasPreviousRole bool, statusRole securityv1alpha1.StatusTemplateRole) {
+	isChanged := !hasPreviousRole || previousRole.Status != statusRole.Status || previousRole.Error != statusRole.Error
+	switch {
+	case statusRole.Status == "Deployed" && !hasPreviousRole:
+		recordNormal(r.Recorder, roleTemplate, reasonCreated, "Created role %v for namespace %v", statusRole.Name, statusRole.Namespace)
+	case statusRole.Status == "Deployed" && (isChanged || roleTemplate.Generation != roleTemplate.Status.ObservedGeneration):
+		recordNormal(r.Recorder, roleTemplate, reasonUpdated, "Updated role %v for namespace %v", statusRole.Name, statusRole.Namespace)
+	case statusRole.Status == "Deployed" || !isChanged:
+	case statusRole.Status == "Conflict":
+		recordWarning(r.Recorder, roleTemplate, reasonConflict, statusRole.Error)
+	case statusRole.Status == "Invalid":
+		recordWarning(r.Recorder, roleTemplate, reasonInvalid, "Role "+statusRole.Name+": "+statusRole.Error)
+	default:
+		recordWarning(r.Recorder, roleTemplate, reasonFailed, "Role "+statusRole.Name+": "+statusRole.Error)
+	}
+}
+
+// SetRoleTemplateStatus - set status and update CR, if status is changed
+func (r *RoleTemplateReconciler) SetRoleTemplateStatus(ctx context.Context, roleTemplate *securityv1alpha1.RoleTemplate, status, message string, statusRoles []securityv1alpha1.StatusTemplateRole) error {
+	// Errors of roles can quote API responses
+	for i := range statusRoles {
+		statusRoles[i].Error = elasticsearch_api_client.RedactText(statusRoles[i].Error)
+	}
+	observedGeneration := roleTemplate.Status.ObservedGeneration
+	if status == "Deployed" {
+		observedGeneration = roleTemplate.Generation
+	}
+	newStatus := securityv1alpha1.RoleTemplateStatus{
+		Status:             status,
+		Error:              elasticsearch_api_client.RedactText(message),
+		Roles:              statusRoles,
+		ObservedGeneration: observedGeneration,
+		Conditions:         append([]metav1.Condition(nil), roleTemplate.Status.Conditions...),
+	}
+	var conflicts []string
+	for _, statusRole := range statusRoles {
+		if statusRole.Status == "Conflict" {
+			conflicts = append(conflicts, statusRole.Name+": "+statusRole.Error)
+		}
+	}
+	if len(conflicts) > 0 {
+		setConflictCondition(&newStatus.Conditions, roleTemplate.Generation, "Conflict", strings.Join(conflicts, "; "))
+	} else {
+		setConflictCondition(&newStatus.Conditions, roleTemplate.Generation, status, "")
+	}
+	if equality.Semantic.DeepEqual(roleTemplate.Status, newStatus) {
+		return nil
+	}
+	roleTemplate.Status = newStatus
+	if err := r.Client.Status().Update(ctx, roleTemplate); err != nil {
+		return errors.New("Error when setting status: " + err.Error())
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RoleTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&securityv1alpha1.RoleTemplate{}).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.allRoleTemplates)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: config.AppConfig.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// allRoleTemplates - enqueue every role template, because any of them may start or stop matching namespace
+func (r *RoleTemplateReconciler) allRoleTemplates(namespace client.Object) []reconcile.Request {
+	roleTemplateList := &securityv1alpha1.RoleTemplateList{}
+	if err := r.List(context.TODO(), roleTemplateList); err != nil {
+		r.Log.Error(err, "Error when listing role templates for namespace", "namespace", namespace.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(roleTemplateList.Items))
+	for _, roleTemplate := range roleTemplateList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: roleTemplate.Name}})
+	}
+	return requests
+}
+
+// FinalizeRoleTemplate delete all roles, created from template
+func (r *RoleTemplateReconciler) FinalizeRoleTemplate(ctx context.Context, roleTemplate *securityv1alpha1.RoleTemplate) error {
+	log := ctrl.LoggerFrom(ctx)
+	for _, statusRole := range roleTemplate.Status.Roles {
+		if statusRole.Status == "Conflict" {
+			continue
+		}
+		if err := DeleteTemplateRole(ctx, r.Recorder, roleTemplate, statusRole, EffectiveDeletionPolicy(roleTemplate.Spec.Role.DeletionPolicy)); err != nil {
+			log.Error(err, "Error when finalyzing role template", "role", statusRole.Name)
+			recordWarning(r.Recorder, roleTemplate, reasonFailed, "Error when deleting role "+statusRole.Name+": "+err.Error())
+			return fmt.Errorf("Error when deleting role %v: %v", statusRole.Name, err.Error())
+		}
+	}
+	ForgetSync("roletemplate", roleTemplate)
+	log.Info("Successfully finalized role template")
+	return nil
+}
@@ -22,52 +22,58 @@ import (
 	"errors"
 
 	"github.com/go-logr/logr"
-	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
 	config "github.com/aberestyak/elasticsearch-security-operator/config"
 	users "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/users"
+	"github.com/aberestyak/elasticsearch-security-operator/internal/logger"
 )
 
 // UserReconciler reconciles a User object
 type UserReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+	ChangeLog *ChangeLog
 }
 
-var userControllerLogger = log.WithFields(log.Fields{
-	"component": "UserController",
-})
-
 const userFinalizer = "user.security.rshbdev.ru/finalizer"
 
 //+kubebuilder:rbac:groups=security.rshbdev.ru,resources=users,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=security.rshbdev.ru,resources=users/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=security.rshbdev.ru,resources=users/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile main reconcile loop
 func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, log, span := reconcileContext(ctx, r.Log, "User", req)
+	defer span.End()
 	desiredUser := &securityv1alpha1.User{}
 	var err = r.Get(ctx, req.NamespacedName, desiredUser)
 	if err != nil {
 		if kerrors.IsNotFound(err) {
-			userControllerLogger.Info("Resource was deleted")
+			log.Info("Resource was deleted")
 			return ctrl.Result{}, nil
 		}
-		userControllerLogger.Errorf("Error while reading CR User: %v", err)
+		log.Error(err, "Error while reading CR User")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	ctx = r.ChangeLog.WithSource(ctx, desiredUser)
 	// Call finalyzer to clean up
 	isdesiredUserToBeDeleted := desiredUser.GetDeletionTimestamp() != nil
 	if isdesiredUserToBeDeleted {
 		if controllerutil.ContainsFinalizer(desiredUser, userFinalizer) {
-			if err := r.FinalizeUser(desiredUser); err != nil {
+			if err := r.FinalizeUser(ctx, desiredUser); err != nil {
 				return ctrl.Result{}, err
 			}
 			controllerutil.RemoveFinalizer(desiredUser, userFinalizer)
@@ -86,49 +92,163 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 			return ctrl.Result{}, err
 		}
 	}
+	// Refuse to manage elasticsearch user, which belongs to User from another namespace
+	userName := EffectiveUserName(desiredUser)
+	log = log.WithValues("user", userName)
+	ctx = ctrl.LoggerInto(ctx, log)
+	conflict, err := CheckUserNameConflict(ctx, r.Client, desiredUser, userName)
+	if err != nil {
+		log.Error(err, "Error when checking user name conflicts")
+		return ctrl.Result{}, err
+	}
+	if conflict != "" {
+		log.Info("User conflicts with another user", "reason", conflict)
+		recordWarning(r.Recorder, desiredUser, reasonConflict, conflict)
+		if err := SetUserStatus(ctx, r, desiredUser, desiredUser.Status.Name, "Conflict", []byte(conflict)); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	// Status keeps name of managed user, until user is deployed with new name
+	previousName := desiredUser.Status.Name
+	isRenamed := previousName != "" && previousName != userName
+
 	// Map model to UserAPISpec
 	userAPIObject, err := MapUserAPIObject(desiredUser)
 	if err != nil {
-		roleControllerLogger.Errorf("Error when mapping models : %v", err)
+		log.Error(err, "Error when mapping models")
+		recordWarning(r.Recorder, desiredUser, reasonInvalid, err.Error())
+		return ctrl.Result{}, err
+	}
+	// Tag user, so it is not taken over by another resource or operator
+	userAPIObject.Attributes = map[string]string{ownershipKey: string(desiredUser.UID)}
+
+	// Refuse to overwrite user, created by hand or by another operator
+	userExists, existingUserSpec, err := GetExistingObject(ctx, config.AppConfig.ElasticsearchUserAPIPath, userName)
+	if err != nil {
+		log.Error(err, "Error when checking user existence")
+		return ctrl.Result{}, err
+	}
+	if userExists {
+		existingUser := make(map[string]users.UserAPISpec, 1)
+		if err := json.Unmarshal(existingUserSpec, &existingUser); err != nil {
+			log.Error(err, "Error when unmarshaling existing user")
+			return ctrl.Result{}, err
+		}
+		// Refuse to modify built-in user
+		protection, err := CheckProtected("user", userName, existingUserSpec)
+		if err != nil {
+			log.Error(err, "Error when checking user protection")
+			return ctrl.Result{}, err
+		}
+		if protection != "" {
+			log.Info("User targets protected user", "reason", protection)
+			recordWarning(r.Recorder, desiredUser, reasonProtected, protection)
+			if err := SetUserStatus(ctx, r, desiredUser, desiredUser.Status.Name, "Protected", []byte(protection)); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+		owner := existingUser[userName].Attributes[ownershipKey]
+		if conflict := CheckOwnership("user", userName, owner, desiredUser.UID, desiredUser.Spec.Adopt, desiredUser.Status.Status == "Deployed" && !isRenamed); conflict != "" {
+			log.Info("User conflicts with existing user", "reason", conflict)
+			recordWarning(r.Recorder, desiredUser, reasonConflict, conflict)
+			if err := SetUserStatus(ctx, r, desiredUser, desiredUser.Status.Name, "Conflict", []byte(conflict)); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+		// Attributes, set outside of operator, are kept
+		for key, value := range existingUser[userName].Attributes {
+			if key != ownershipKey {
+				userAPIObject.Attributes[key] = value
+			}
+		}
+		// Can't get hash from elasticsearch, so it is applied only when spec was changed or user wasn't deployed
+		if existingUser[userName].Equal(*userAPIObject) && desiredUser.Generation == desiredUser.Status.ObservedGeneration && desiredUser.Status.Status == "Deployed" && !isRenamed {
+			log.V(logger.Debug).Info("User is up to date")
+			RecordSuccessfulSync("user", desiredUser)
+			return ctrl.Result{}, nil
+		}
 	}
+
 	apiUserJSON, err := json.Marshal(userAPIObject)
 	if err != nil {
-		roleControllerLogger.Errorf("Error when marshaling user object: %v", err)
+		log.Error(err, "Error when marshaling user object")
 		return ctrl.Result{}, err
 	}
 
-	// Can't get hash from elasticsearch, so can't check changed or not
-	if err := CreateOrUpdateUser(r, desiredUser, apiUserJSON); err != nil {
+	// Deployed user with unchanged spec is updated only when its attributes were changed outside of operator
+	reason := reasonCreated
+	if userExists {
+		reason = ""
+		if desiredUser.Generation != desiredUser.Status.ObservedGeneration {
+			reason = reasonUpdated
+		} else if desiredUser.Status.Status == "Deployed" {
+			reason = reasonDriftCorrected
+			RecordDriftCorrection("user")
+		}
+	}
+	if err := CreateOrUpdateUser(ctx, r, desiredUser, userName, apiUserJSON, reason); err != nil {
 		return ctrl.Result{}, err
 	}
+	if desiredUser.Status.Status == "Deployed" {
+		RecordSuccessfulSync("user", desiredUser)
+	}
 	return ctrl.Result{}, nil
 }
 
-// CreateOrUpdateUser - make PUT request to create or update User
-func CreateOrUpdateUser(r *UserReconciler, user *securityv1alpha1.User, jsonUser []byte) error {
-	_, responseResult, responseBody, err := MakeAPIRequest("PUT", config.AppConfig.ElasticsearchUserAPIPath+"/"+user.Name, jsonUser)
+// CreateOrUpdateUser - make PUT request to create or update User with passed name and emit event with passed reason, if it isn't empty.
+// User with previous name from status is released after user with new name is deployed
+func CreateOrUpdateUser(ctx context.Context, r *UserReconciler, user *securityv1alpha1.User, name string, jsonUser []byte, reason string) error {
+	responseResult, responseBody, err := PutObject(ctx, config.AppConfig.ElasticsearchUserAPIPath, name, jsonUser)
 	if err != nil {
 		return errors.New("Error when creating new user: " + err.Error())
 	}
-	if err := SetUserStatus(r, user, responseResult, responseBody); err != nil {
+	if reason != "" || responseResult != "Deployed" {
+		recordAPIResult(r.Recorder, user, responseResult, reason, reason+" elasticsearch user "+name, responseBody)
+	}
+	if responseResult == "Deployed" && user.Status.Name != name {
+		if err := ReleaseRenamedUser(ctx, r, user, name); err != nil {
+			recordWarning(r.Recorder, user, reasonFailed, err.Error())
+			return err
+		}
+	}
+	statusName := user.Status.Name
+	if responseResult == "Deployed" {
+		statusName = name
+	}
+	if err := SetUserStatus(ctx, r, user, statusName, responseResult, responseBody); err != nil {
 		return err
 	}
-	userControllerLogger.Infof("Updated user: %v", user.Name)
+	ctrl.LoggerFrom(ctx).Info("Updated elasticsearch user", "state", responseResult)
 	return nil
 }
 
-// SetUserStatus - parse http response code, set status and update CR
-func SetUserStatus(r *UserReconciler, user *securityv1alpha1.User, responseResult string, responseBody []byte) error {
+// SetUserStatus - parse http response code, set status with name of managed user and update CR, if status is changed
+func SetUserStatus(ctx context.Context, r *UserReconciler, user *securityv1alpha1.User, name, responseResult string, responseBody []byte) error {
+	observedGeneration := user.Status.ObservedGeneration
+	if responseResult == "Deployed" {
+		observedGeneration = user.Generation
+	}
+	oldStatus := user.Status
 	user.Status = securityv1alpha1.UserStatus{
-		Status: responseResult,
+		Status:             responseResult,
+		Name:               name,
+		ObservedGeneration: observedGeneration,
+		Conditions:         append([]metav1.Condition(nil), user.Status.Conditions...),
 		Error: func(response string, responseBody []byte) string {
-			if response == "Error" {
-				return string(responseBody)
+			if response != "Deployed" {
+				return responseText(responseBody)
 			}
 			return ""
 		}(responseResult, responseBody),
 	}
-	if err := r.Client.Status().Update(context.TODO(), user); err != nil {
+	setConflictCondition(&user.Status.Conditions, user.Generation, responseResult, user.Status.Error)
+	if equality.Semantic.DeepEqual(oldStatus, user.Status) {
+		return nil
+	}
+	if err := r.Client.Status().Update(ctx, user); err != nil {
 		return errors.New("Error when setting status: " + err.Error())
 	}
 	return nil
@@ -148,16 +268,61 @@ func MapUserAPIObject(user *securityv1alpha1.User) (*users.UserAPISpec, error) {
 func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&securityv1alpha1.User{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: config.AppConfig.MaxConcurrentReconciles}).
 		Complete(r)
 }
 
 // FinalizeUser delete user
-func (r *UserReconciler) FinalizeUser(user *securityv1alpha1.User) error {
-	_, _, _, err := MakeAPIRequest("DELETE", config.AppConfig.ElasticsearchUserAPIPath+"/"+user.Name, nil)
-	if err != nil {
-		userControllerLogger.Errorf("Error when finalyzing user: %v", err.Error())
+func (r *UserReconciler) FinalizeUser(ctx context.Context, user *securityv1alpha1.User) error {
+	log := ctrl.LoggerFrom(ctx)
+	// Elasticsearch user belongs to another User or is built-in. User with previous name is still managed
+	if (user.Status.Status == "Conflict" || user.Status.Status == "Protected") && (user.Status.Name == "" || user.Status.Name == EffectiveUserName(user)) {
+		log.Info("User doesn't manage elasticsearch user, skip deletion")
+		return nil
+	}
+	if deletionPolicy := EffectiveDeletionPolicy(user.Spec.DeletionPolicy); KeepsObjects(deletionPolicy) {
+		log.Info("Keep elasticsearch user", "deletionPolicy", deletionPolicy)
+		recordNormal(r.Recorder, user, reasonOrphaned, "Kept elasticsearch user with %v deletion policy", deletionPolicy)
+		ForgetSync("user", user)
+		return nil
+	}
+	// Users, deployed before naming strategy was introduced, have no name in status
+	userName := user.Status.Name
+	if userName == "" {
+		userName = EffectiveUserName(user)
+	}
+	if err := DeleteUser(ctx, userName); err != nil {
+		log.Error(err, "Error when finalyzing user")
+		recordWarning(r.Recorder, user, reasonFailed, err.Error())
 		return err
 	}
-	userControllerLogger.Infof("Successfully finalized user: %v", user.Name)
+	ForgetSync("user", user)
+	recordNormal(r.Recorder, user, reasonDeleted, "Deleted elasticsearch user %v", userName)
+	log.Info("Successfully finalized user")
 	return nil
 }
+
+// ReleaseRenamedUser - delete user with previous name from status according to deletion policy.
+// New name is recorded in status by SetUserStatus
+func ReleaseRenamedUser(ctx context.Context, r *UserReconciler, user *securityv1alpha1.User, name string) error {
+	previousName := user.Status.Name
+	if previousName != "" {
+		log := ctrl.LoggerFrom(ctx).WithValues("previousUser", previousName)
+		if deletionPolicy := EffectiveDeletionPolicy(user.Spec.DeletionPolicy); KeepsObjects(deletionPolicy) {
+			log.Info("Keep elasticsearch user with previous name", "deletionPolicy", deletionPolicy)
+			recordNormal(r.Recorder, user, reasonOrphaned, "Kept elasticsearch user %v with %v deletion policy", previousName, deletionPolicy)
+		} else {
+			if err := DeleteUser(ctx, previousName); err != nil {
+				return err
+			}
+			log.Info("Deleted elasticsearch user with previous name")
+			recordNormal(r.Recorder, user, reasonDeleted, "Deleted elasticsearch user %v, renamed to %v", previousName, name)
+		}
+	}
+	return nil
+}
+
+// DeleteUser - make DELETE request to delete internal user, unless it is protected
+func DeleteUser(ctx context.Context, name string) error {
+	return DeleteUnprotectedObject(ctx, "user", config.AppConfig.ElasticsearchUserAPIPath, name)
+}
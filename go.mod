@@ -3,12 +3,13 @@ module github.com/aberestyak/elasticsearch-security-operator
 go 1.15
 
 require (
-	github.com/antonfisher/nested-logrus-formatter v1.3.1
 	github.com/go-logr/logr v0.4.0
 	github.com/onsi/ginkgo v1.14.1
 	github.com/onsi/gomega v1.10.2
-	github.com/sirupsen/logrus v1.8.1
+	github.com/prometheus/client_golang v1.7.1
 	github.com/spf13/viper v1.7.1
+	go.uber.org/zap v1.15.0
+	k8s.io/api v0.19.2
 	k8s.io/apimachinery v0.21.0
 	k8s.io/client-go v0.19.2
 	sigs.k8s.io/controller-runtime v0.7.2
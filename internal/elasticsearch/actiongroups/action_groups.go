@@ -0,0 +1,17 @@
+package esapiactiongroups
+
+import (
+	esapicompare "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/compare"
+)
+
+// ActionGroupAPISpec defines ES action groups API
+type ActionGroupAPISpec struct {
+	AllowedActions []string `json:"allowed_actions"`
+	Type           string   `json:"type,omitempty"`
+	Description    string   `json:"description,omitempty"`
+}
+
+// Equal - semantic comparison of action groups, order and duplicates of allowed actions don't matter
+func (g ActionGroupAPISpec) Equal(other ActionGroupAPISpec) bool {
+	return g.Type == other.Type && g.Description == other.Description && esapicompare.Sets(g.AllowedActions, other.AllowedActions)
+}
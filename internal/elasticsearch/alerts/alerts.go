@@ -10,6 +10,8 @@ type AlertAPISpec struct {
 	Schedule MonitorSchedule  `json:"schedule"`
 	Inputs   []MonitorInput   `json:"inputs"`
 	Triggers []MonitorTrigger `json:"triggers"`
+	// Used by operator to tag managed monitors
+	UIMetadata map[string]interface{} `json:"ui_metadata,omitempty"`
 }
 
 // MonitorTrigger defines triggers and required actions
@@ -22,10 +24,12 @@ type MonitorTrigger struct {
 
 // TriggerAction defines alerting destination and templates
 type TriggerAction struct {
-	Name            string       `json:"name"`
-	Destination     string       `json:"destination_id"`
-	SubjectTemplate TextTemplate `json:"subject_template"`
-	MessageTemplate TextTemplate `json:"message_template"`
+	Name            string           `json:"name"`
+	Destination     string           `json:"destination_id"`
+	SubjectTemplate TextTemplate     `json:"subject_template"`
+	MessageTemplate TextTemplate     `json:"message_template"`
+	ThrottleEnabled bool             `json:"throttle_enabled"`
+	Throttle        *TriggerThrottle `json:"throttle,omitempty"`
 }
 
 // TriggerThrottle defines alerting throttle
@@ -73,3 +77,72 @@ type SchedulePeroid struct {
 	Interval int    `json:"interval"`
 	Unit     string `json:"unit"`
 }
+
+// MonitorGetResponse defines response of monitor GET API
+type MonitorGetResponse struct {
+	ID      string       `json:"_id"`
+	Monitor AlertAPISpec `json:"monitor"`
+}
+
+// MonitorSearchResponse defines response of monitors _search API
+type MonitorSearchResponse struct {
+	Hits MonitorSearchHits `json:"hits"`
+}
+
+// MonitorSearchHits defines found monitors
+type MonitorSearchHits struct {
+	Hits []MonitorSearchHit `json:"hits"`
+}
+
+// MonitorSearchHit defines single found monitor
+type MonitorSearchHit struct {
+	ID     string       `json:"_id"`
+	Source AlertAPISpec `json:"_source"`
+}
+
+// MonitorExecuteResponse defines response of monitor _execute API
+type MonitorExecuteResponse struct {
+	MonitorName    string                          `json:"monitor_name"`
+	Error          string                          `json:"error"`
+	InputResults   ExecuteInputResults             `json:"input_results"`
+	TriggerResults map[string]ExecuteTriggerResult `json:"trigger_results"`
+}
+
+// ExecuteInputResults defines result of monitor inputs execution
+type ExecuteInputResults struct {
+	Error string `json:"error"`
+}
+
+// ExecuteTriggerResult defines result of trigger condition execution
+type ExecuteTriggerResult struct {
+	Name      string `json:"name"`
+	Triggered bool   `json:"triggered"`
+	Error     string `json:"error"`
+}
+
+// MonitorAlertsResponse defines response of monitor alerts API
+type MonitorAlertsResponse struct {
+	Alerts      []MonitorAlert `json:"alerts"`
+	TotalAlerts int            `json:"totalAlerts"`
+}
+
+// MonitorAlert defines alert, generated by monitor's trigger
+type MonitorAlert struct {
+	ID          string `json:"id"`
+	MonitorID   string `json:"monitor_id"`
+	TriggerName string `json:"trigger_name"`
+	State       string `json:"state"`
+	Severity    string `json:"severity"`
+	StartTime   int64  `json:"start_time"`
+}
+
+// AcknowledgeRequest defines request of monitor _acknowledge/alerts API
+type AcknowledgeRequest struct {
+	Alerts []string `json:"alerts"`
+}
+
+// AcknowledgeResponse defines response of monitor _acknowledge/alerts API
+type AcknowledgeResponse struct {
+	Success []string          `json:"success"`
+	Failed  []json.RawMessage `json:"failed"`
+}
@@ -2,14 +2,19 @@ package esapiclient
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
 
 	appConfig "github.com/aberestyak/elasticsearch-security-operator/config"
-	log "github.com/sirupsen/logrus"
+	"github.com/aberestyak/elasticsearch-security-operator/internal/logger"
+	"github.com/aberestyak/elasticsearch-security-operator/internal/tracing"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // APIClient defines elasticsearch API client config
@@ -24,6 +29,8 @@ type Configuration struct {
 	UserAgent     string            `json:"userAgent,omitempty"`
 	BasicAuth     BasicAuth         `json:"basicAuth,omitempty"`
 	HTTPClient    *http.Client
+	// Requests are made concurrently, HTTP client with custom CA is set up once
+	caCertOnce sync.Once
 }
 
 // BasicAuth defins basic auth configuration for http client
@@ -33,9 +40,7 @@ type BasicAuth struct {
 }
 
 var (
-	apiClientLogger = log.WithFields(log.Fields{
-		"component": "ApiClient",
-	})
+	apiClientLogger = logf.Log.WithName("ApiClient")
 )
 
 func (c *APIClient) callAPI(request *http.Request) (*http.Response, error) {
@@ -45,20 +50,21 @@ func (c *APIClient) callAPI(request *http.Request) (*http.Response, error) {
 func (c *APIClient) doAPIRequest(request *http.Request) ([]byte, *http.Response, error) {
 	httpResponse, err := c.callAPI(request)
 	if err != nil || httpResponse == nil {
-		apiClientLogger.Error(err)
+		apiClientLogger.Error(err, "Error when calling API", "url", request.URL.Path)
 		return nil, httpResponse, err
 	}
 	responseBody, err := ioutil.ReadAll(httpResponse.Body)
 	_ = httpResponse.Body.Close()
 	if err != nil {
-		apiClientLogger.Error(err)
+		apiClientLogger.Error(err, "Error when reading response body", "url", request.URL.Path)
 		return nil, httpResponse, err
 	}
 	return responseBody, httpResponse, err
 }
 
-// PrepareAndCall prepare http request and do it
+// PrepareAndCall prepare http request and do it. Request is traced as child of span in context
 func (c *APIClient) PrepareAndCall(
+	ctx context.Context,
 	path string,
 	method string,
 	postBody []byte,
@@ -67,10 +73,30 @@ func (c *APIClient) PrepareAndCall(
 
 	r, err := c.prepareRequest(path, method, postBody, headerParams, queryParams)
 	if err != nil {
-		apiClientLogger.Error(err)
+		apiClientLogger.Error(err, "Error when preparing request", "path", path)
 		return nil, nil, err
 	}
-	return c.doAPIRequest(r)
+	ctx, span := tracing.StartChild(ctx, method+" "+apiKind(path), tracing.SpanKindClient, "http.request.method", method, "url.path", path)
+	defer span.End()
+	// Request is canceled with context
+	r = r.WithContext(ctx)
+	tracing.Inject(ctx, r.Header)
+	apiClientLogger.V(logger.Trace).Info("Sending request", "method", method, "url", r.URL.Path, "headers", RedactHeaders(r.Header))
+	start := time.Now()
+	responseBody, httpResponse, err := c.doAPIRequest(r)
+	statusCode := 0
+	if httpResponse != nil {
+		statusCode = httpResponse.StatusCode
+		span.SetAttributes("http.response.status_code", statusCode)
+	}
+	switch {
+	case err != nil:
+		span.SetError(err.Error())
+	case statusCode >= 400:
+		span.SetError(http.StatusText(statusCode))
+	}
+	observeRequest(method, path, statusCode, start)
+	return responseBody, httpResponse, err
 }
 
 func (c *APIClient) prepareRequest(
@@ -83,7 +109,7 @@ func (c *APIClient) prepareRequest(
 	// Setup path and query parameters
 	parsedURL, err := url.Parse(c.Cfg.Host + "/" + path)
 	if err != nil {
-		apiClientLogger.Error(err)
+		apiClientLogger.Error(err, "Error when parsing URL", "path", path)
 		return nil, err
 	}
 
@@ -138,7 +164,7 @@ func (c *APIClient) prepareRequest(
 	}
 	// Add custom CA certificate if appropriate env is set
 	if appConfig.AppConfig.ExtraCACert != nil {
-		c.addCustomCACert()
+		c.Cfg.caCertOnce.Do(c.addCustomCACert)
 	}
 	return localVarRequest, nil
 }
@@ -156,5 +182,5 @@ func (c *APIClient) addCustomCACert() {
 	}
 	tlsConfig.BuildNameToCertificate()
 	transport := &http.Transport{TLSClientConfig: tlsConfig}
-	c.Cfg.HTTPClient = &http.Client{Transport: transport}
+	c.Cfg.HTTPClient = &http.Client{Transport: transport, Timeout: c.Cfg.HTTPClient.Timeout}
 }
@@ -0,0 +1,74 @@
+package esapicompare
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// Set - sorted list without duplicates, nil and empty lists are the same
+func Set(list []string) []string {
+	if len(list) == 0 {
+		return nil
+	}
+	set := append([]string(nil), list...)
+	sort.Strings(set)
+	unique := set[:1]
+	for _, item := range set[1:] {
+		if item != unique[len(unique)-1] {
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
+
+// Sets - lists contain the same items, order and duplicates don't matter
+func Sets(a, b []string) bool {
+	a, b = Set(a), Set(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Maps - maps contain the same items, nil and empty maps are the same
+func Maps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// JSON - canonical form of JSON document: compact, with sorted keys. Document may be encoded as JSON string,
+// like DLS query in roles API. Empty document and null are empty string, invalid JSON is returned as is
+func JSON(raw []byte) string {
+	raw = bytes.TrimSpace(raw)
+	var encoded string
+	if len(raw) > 0 && raw[0] == '"' && json.Unmarshal(raw, &encoded) == nil {
+		raw = bytes.TrimSpace([]byte(encoded))
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil || decoder.More() {
+		return string(raw)
+	}
+	canonical, err := json.Marshal(document)
+	if err != nil {
+		return string(raw)
+	}
+	return string(canonical)
+}
@@ -0,0 +1,75 @@
+package esapiclient
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	appConfig "github.com/aberestyak/elasticsearch-security-operator/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	apiRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "elasticsearch_security_operator_api_requests_total",
+		Help: "Number of requests to Elasticsearch API by method, API kind and status code",
+	}, []string{"method", "kind", "code"})
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "elasticsearch_security_operator_api_request_duration_seconds",
+		Help:    "Latency of requests to Elasticsearch API by method, API kind and status code",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "kind", "code"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(apiRequestsTotal, apiRequestDuration)
+}
+
+// observeRequest - record request count and latency. Failed requests without response have "error" code
+func observeRequest(method, path string, statusCode int, start time.Time) {
+	code := "error"
+	if statusCode != 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	kind := apiKind(path)
+	apiRequestsTotal.WithLabelValues(method, kind, code).Inc()
+	apiRequestDuration.WithLabelValues(method, kind, code).Observe(time.Since(start).Seconds())
+}
+
+// apiKind - kind of security object by configured API path. Path itself is not used as label to keep cardinality low
+func apiKind(path string) string {
+	kind, _ := APIObject(path)
+	return kind
+}
+
+// APIObject - kind of security object by configured API path and object name (ID for monitors), if path points to object
+func APIObject(path string) (kind string, name string) {
+	kinds := []struct {
+		kind string
+		path string
+	}{
+		{"role", appConfig.AppConfig.ElasticsearchRoleAPIPath},
+		{"rolemapping", appConfig.AppConfig.ElasticsearchRoleMappingAPIPath},
+		{"user", appConfig.AppConfig.ElasticsearchUserAPIPath},
+		{"tenant", appConfig.AppConfig.ElasticsearchTenantAPIPath},
+		{"actiongroup", appConfig.AppConfig.ElasticsearchActionGroupAPIPath},
+		{"monitor", appConfig.AppConfig.ElasticsearchAlertAPIPath},
+	}
+	for _, k := range kinds {
+		if k.path == "" {
+			continue
+		}
+		if path == k.path {
+			return k.kind, ""
+		}
+		if strings.HasPrefix(path, k.path+"/") {
+			name = strings.TrimPrefix(path, k.path+"/")
+			if i := strings.IndexAny(name, "/?"); i >= 0 {
+				name = name[:i]
+			}
+			return k.kind, name
+		}
+	}
+	return "other", ""
+}
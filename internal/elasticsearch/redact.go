@@ -0,0 +1,131 @@
+package esapiclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	appConfig "github.com/aberestyak/elasticsearch-security-operator/config"
+)
+
+// Redacted - replacement of secret values
+const Redacted = "<redacted>"
+
+// Fields and headers, which values are secrets
+var (
+	sensitiveFields = []string{
+		"password",
+		"current_password",
+		"hash",
+		"authorization",
+		"token",
+		"secret",
+		"secret_key",
+		"access_key",
+		"api_key",
+		"private_key",
+	}
+	sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+	// "password": "value", password=value, \"password\":\"value\", Authorization: Basic value
+	sensitiveAssignment = regexp.MustCompile(`(?i)(\\?"?\b(?:` + strings.Join(sensitiveFields, "|") + `)\b\\?"?\s*[:=]\s*)((?:Basic|Bearer)\s+[^\s,;&}"]+|\\?"[^"\\]*\\?"|[^\s,;&}"]+)`)
+	// Authorization: Basic dXNlcjpwYXNz, Bearer token
+	authScheme = regexp.MustCompile(`(?i)\b(Basic|Bearer)\s+[A-Za-z0-9+/=._~-]+`)
+	// bcrypt hashes of internal users
+	bcryptHash = regexp.MustCompile(`\$2[aby]?\$\d{2}\$[./A-Za-z0-9]{53}`)
+)
+
+// RedactBody - mask secrets in request or response body before it is logged, recorded in event or stored in status.
+// Values of sensitive fields in JSON are replaced at any depth, other text is masked by patterns
+func RedactBody(body []byte) []byte {
+	return redactBody(body, configuredSecrets())
+}
+
+// RedactText - mask secrets in text, such as error message
+func RedactText(text string) string {
+	return redactText(text, configuredSecrets())
+}
+
+// RedactHeaders - copy headers with masked credentials
+func RedactHeaders(headers http.Header) http.Header {
+	redacted := headers.Clone()
+	for _, header := range sensitiveHeaders {
+		if redacted.Get(header) != "" {
+			redacted.Set(header, Redacted)
+		}
+	}
+	return redacted
+}
+
+// configuredSecrets - credentials of operator user, which must never appear in output
+func configuredSecrets() []string {
+	if appConfig.AppConfig.ElasticsearchPassword == "" {
+		return nil
+	}
+	return []string{
+		appConfig.AppConfig.ElasticsearchPassword,
+		basicAuth(appConfig.AppConfig.ElasticsearchUsername, appConfig.AppConfig.ElasticsearchPassword),
+	}
+}
+
+func redactBody(body []byte, secrets []string) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	var parsed interface{}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return []byte(redactText(string(body), secrets))
+	}
+	// Keep <redacted> readable instead of \u003credacted\u003e
+	var redacted bytes.Buffer
+	encoder := json.NewEncoder(&redacted)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(redactValue(parsed, secrets)); err != nil {
+		return []byte(Redacted)
+	}
+	return bytes.TrimSuffix(redacted.Bytes(), []byte("\n"))
+}
+
+func redactValue(value interface{}, secrets []string) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			if isSensitiveField(key) {
+				typed[key] = Redacted
+			} else {
+				typed[key] = redactValue(item, secrets)
+			}
+		}
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = redactValue(item, secrets)
+		}
+	case string:
+		// Error reasons can quote rejected request
+		return redactText(typed, secrets)
+	}
+	return value
+}
+
+func redactText(text string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, Redacted)
+		}
+	}
+	text = sensitiveAssignment.ReplaceAllString(text, "${1}"+Redacted)
+	text = authScheme.ReplaceAllString(text, "${1} "+Redacted)
+	return bcryptHash.ReplaceAllString(text, Redacted)
+}
+
+func isSensitiveField(key string) bool {
+	key = strings.ToLower(key)
+	for _, field := range sensitiveFields {
+		if key == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package esapiclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	testPassword = "s3cr3t-Pa55"
+	testHash     = "$2y$12$pgCpvSAS2W4zTi7ML4bmmOY6ot95EpD4SU9zQ4i5Dg9aVIVBdVa6S"
+)
+
+func TestRedactBody(t *testing.T) {
+	secrets := []string{"operator-pass", basicAuth("admin", "operator-pass")}
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+		keep     []string
+	}{
+		{
+			"user password",
+			`{"password":"` + testPassword + `","backend_roles":["admin"]}`,
+			`{"backend_roles":["admin"],"password":"<redacted>"}`,
+			nil,
+		},
+		{
+			"user hash",
+			`{"kibanaro":{"hash":"` + testHash + `","reserved":false}}`,
+			`{"kibanaro":{"hash":"<redacted>","reserved":false}}`,
+			nil,
+		},
+		{
+			"nested in list",
+			`{"users":[{"name":"a","Password":"` + testPassword + `"}]}`,
+			`{"users":[{"Password":"<redacted>","name":"a"}]}`,
+			nil,
+		},
+		{
+			"quoted request in error reason",
+			`{"status":"BAD_REQUEST","reason":"Invalid configuration {\"password\":\"` + testPassword + `\"}"}`,
+			"",
+			[]string{"BAD_REQUEST", "Invalid configuration"},
+		},
+		{
+			"hash in error reason",
+			`{"status":"error","message":"hash ` + testHash + ` is invalid"}`,
+			`{"message":"hash <redacted> is invalid","status":"error"}`,
+			nil,
+		},
+		{
+			"text with assignment",
+			"password=" + testPassword + "&user=admin",
+			"password=<redacted>&user=admin",
+			nil,
+		},
+		{
+			"text with escaped JSON",
+			`Error: {\"password\":\"` + testPassword + `\",\"hash\":\"` + testHash + `\"}`,
+			"",
+			[]string{"Error:"},
+		},
+		{
+			"authorization header in text",
+			"request failed, Authorization: Basic " + basicAuth("admin", testPassword),
+			"request failed, Authorization: <redacted>",
+			nil,
+		},
+		{
+			"configured password",
+			`{"reason":"user admin with password operator-pass is unauthorized"}`,
+			`{"reason":"user admin with password <redacted> is unauthorized"}`,
+			nil,
+		},
+		{
+			"no secrets",
+			`{"cluster_permissions":["cluster_composite_ops"],"description":"password policy"}`,
+			`{"cluster_permissions":["cluster_composite_ops"],"description":"password policy"}`,
+			nil,
+		},
+		{"empty", "", "", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			redacted := string(redactBody([]byte(test.body), secrets))
+			for _, secret := range append([]string{testPassword, testHash, basicAuth("admin", testPassword)}, secrets...) {
+				if strings.Contains(redacted, secret) {
+					t.Fatalf("redacted body %q contains secret %q", redacted, secret)
+				}
+			}
+			if test.expected != "" && redacted != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, redacted)
+			}
+			for _, keep := range test.keep {
+				if !strings.Contains(redacted, keep) {
+					t.Fatalf("redacted body %q lost %q", redacted, keep)
+				}
+			}
+			if json.Valid([]byte(test.body)) && !json.Valid([]byte(redacted)) {
+				t.Fatalf("redacted body %q is not valid JSON", redacted)
+			}
+		})
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "Basic "+basicAuth("admin", testPassword))
+	headers.Set("Cookie", "security_authentication="+testPassword)
+	headers.Set("Content-Type", "application/json")
+
+	redacted := RedactHeaders(headers)
+	for _, header := range []string{"Authorization", "Cookie"} {
+		if redacted.Get(header) != Redacted {
+			t.Fatalf("header %v is not redacted: %q", header, redacted.Get(header))
+		}
+	}
+	if redacted.Get("Content-Type") != "application/json" {
+		t.Fatalf("Content-Type header is changed: %q", redacted.Get("Content-Type"))
+	}
+	if headers.Get("Authorization") == Redacted {
+		t.Fatal("original headers are modified")
+	}
+}
@@ -1,7 +1,16 @@
 package esapirolemapping
 
+import (
+	esapicompare "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/compare"
+)
+
 // RoleMappingAPISpec defines roleMapping API spec
 type RoleMappingAPISpec struct {
 	Users        []string `json:"users,omitempty"`
 	BackendRoles []string `json:"backend_roles,omitempty"`
 }
+
+// Equal - semantic comparison of role mappings, order of users and backend roles doesn't matter
+func (m RoleMappingAPISpec) Equal(other RoleMappingAPISpec) bool {
+	return esapicompare.Sets(m.Users, other.Users) && esapicompare.Sets(m.BackendRoles, other.BackendRoles)
+}
@@ -2,6 +2,8 @@ package esapiroles
 
 import (
 	"encoding/json"
+
+	esapicompare "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/compare"
 )
 
 // RoleAPISpec defines ES roles API
@@ -26,3 +28,50 @@ type TenantPermissions struct {
 	TenantPatterns []string `json:"tenant_patterns"`
 	AllowedActions []string `json:"allowed_actions"`
 }
+
+// Equal - semantic comparison of roles. Order of permissions, actions and patterns, duplicates, nil and empty lists and
+// formatting of DLS query don't matter. Fields, added by Elasticsearch, like reserved and static, aren't part of spec
+func (r RoleAPISpec) Equal(other RoleAPISpec) bool {
+	return r.Description == other.Description &&
+		esapicompare.Sets(r.ClusterPermissons, other.ClusterPermissons) &&
+		esapicompare.Sets(indexPermissionsSet(r.IndexPermissions), indexPermissionsSet(other.IndexPermissions)) &&
+		esapicompare.Sets(tenantPermissionsSet(r.TenantPermissions), tenantPermissionsSet(other.TenantPermissions))
+}
+
+// indexPermissionsSet - canonical JSON of every index permission, so they can be compared as set
+func indexPermissionsSet(permissions []IndexPermissions) []string {
+	var set []string
+	for _, permission := range permissions {
+		canonical, _ := json.Marshal(struct {
+			IndexPatterns  []string
+			DLS            string
+			FLS            []string
+			MaskedFields   []string
+			AllowedActions []string
+		}{
+			esapicompare.Set(permission.IndexPatterns),
+			esapicompare.JSON(permission.DLS),
+			esapicompare.Set(permission.FLS),
+			esapicompare.Set(permission.MaskedFields),
+			esapicompare.Set(permission.AllowedActions),
+		})
+		set = append(set, string(canonical))
+	}
+	return set
+}
+
+// tenantPermissionsSet - canonical JSON of every tenant permission, so they can be compared as set
+func tenantPermissionsSet(permissions []TenantPermissions) []string {
+	var set []string
+	for _, permission := range permissions {
+		canonical, _ := json.Marshal(struct {
+			TenantPatterns []string
+			AllowedActions []string
+		}{
+			esapicompare.Set(permission.TenantPatterns),
+			esapicompare.Set(permission.AllowedActions),
+		})
+		set = append(set, string(canonical))
+	}
+	return set
+}
@@ -0,0 +1,84 @@
+package esapiroles
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRoleEqual(t *testing.T) {
+	tests := []struct {
+		name     string
+		live     string
+		desired  string
+		expected bool
+	}{
+		{
+			"reordered actions and patterns",
+			`{"cluster_permissions":["a","b"],"index_permissions":[{"index_patterns":["x","y"],"allowed_actions":["read","write"]}]}`,
+			`{"cluster_permissions":["b","a"],"index_permissions":[{"index_patterns":["y","x"],"allowed_actions":["write","read","read"]}]}`,
+			true,
+		},
+		{
+			"reordered index permissions",
+			`{"index_permissions":[{"index_patterns":["x"],"allowed_actions":["read"]},{"index_patterns":["y"],"allowed_actions":["write"]}]}`,
+			`{"index_permissions":[{"index_patterns":["y"],"allowed_actions":["write"]},{"index_patterns":["x"],"allowed_actions":["read"]}]}`,
+			true,
+		},
+		{
+			"empty and missing lists",
+			`{"cluster_permissions":[],"index_permissions":[{"index_patterns":["x"],"fls":[],"masked_fields":[],"allowed_actions":["read"]}],"tenant_permissions":[]}`,
+			`{"index_permissions":[{"index_patterns":["x"],"allowed_actions":["read"]}]}`,
+			true,
+		},
+		{
+			"server-added fields",
+			`{"reserved":false,"hidden":false,"static":false,"index_permissions":[]}`,
+			`{"index_permissions":null}`,
+			true,
+		},
+		{
+			"DLS formatting",
+			`{"index_permissions":[{"index_patterns":["x"],"dls":"{\"bool\": {\"must\": {\"match\": {\"team\": \"a\"}}}}","allowed_actions":["read"]}]}`,
+			`{"index_permissions":[{"index_patterns":["x"],"dls":"{\"bool\":{\"must\":{\"match\":{\"team\":\"a\"}}}}","allowed_actions":["read"]}]}`,
+			true,
+		},
+		{
+			"DLS changed",
+			`{"index_permissions":[{"index_patterns":["x"],"dls":"{\"match\":{\"team\":\"a\"}}","allowed_actions":["read"]}]}`,
+			`{"index_permissions":[{"index_patterns":["x"],"dls":"{\"match\":{\"team\":\"b\"}}","allowed_actions":["read"]}]}`,
+			false,
+		},
+		{
+			"action moved between index permissions",
+			`{"index_permissions":[{"index_patterns":["x"],"allowed_actions":["read"]},{"index_patterns":["y"],"allowed_actions":["write"]}]}`,
+			`{"index_permissions":[{"index_patterns":["x"],"allowed_actions":["write"]},{"index_patterns":["y"],"allowed_actions":["read"]}]}`,
+			false,
+		},
+		{
+			"tenant permissions changed",
+			`{"tenant_permissions":[{"tenant_patterns":["t"],"allowed_actions":["kibana_all_read"]}]}`,
+			`{"tenant_permissions":[{"tenant_patterns":["t"],"allowed_actions":["kibana_all_write"]}]}`,
+			false,
+		},
+		{
+			"description changed",
+			`{"description":"a"}`,
+			`{"description":"b"}`,
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var live, desired RoleAPISpec
+			if err := json.Unmarshal([]byte(test.live), &live); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.desired), &desired); err != nil {
+				t.Fatal(err)
+			}
+			if live.Equal(desired) != test.expected || desired.Equal(live) != test.expected {
+				t.Fatalf("expected equal %v", test.expected)
+			}
+		})
+	}
+}
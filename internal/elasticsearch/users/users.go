@@ -1,6 +1,16 @@
 package esapiusers
 
+import (
+	esapicompare "github.com/aberestyak/elasticsearch-security-operator/internal/elasticsearch/compare"
+)
+
 // UserAPISpec defines ES users API
 type UserAPISpec struct {
-	PasswordHash string `json:"hash"`
+	PasswordHash string            `json:"hash"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// Equal - semantic comparison of users. Elasticsearch doesn't return password hash, so it isn't compared
+func (u UserAPISpec) Equal(other UserAPISpec) bool {
+	return esapicompare.Maps(u.Attributes, other.Attributes)
 }
@@ -4,42 +4,69 @@ import (
 	"os"
 	"strings"
 
-	nested "github.com/antonfisher/nested-logrus-formatter"
-	log "github.com/sirupsen/logrus"
+	"github.com/go-logr/logr"
+	"go.uber.org/zap/zapcore"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 const (
 	defaultLogLevel  = "info"
-	defaultLogFormat = "nested"
+	defaultLogFormat = "text"
 )
 
-// Init initialize logger
-func Init() {
-	logLevelValue := os.Getenv("LOG_LEVEL")
-	logLevel, err := log.ParseLevel(logLevelValue)
-	if err != nil {
-		log.Debugf("Wrong log level set [%v], using default [%v]", logLevelValue, defaultLogLevel)
-		logLevel = log.InfoLevel
+// Verbosity levels of logr Info messages
+const (
+	// Debug - request bodies and other details
+	Debug = 1
+	// Trace - everything else
+	Trace = 2
+)
+
+// Options - zap options from LOG_LEVEL (trace, debug, info, warn, error) and LOG_FORMAT (text, json) environment variables.
+// Command line flags override them
+func Options() zap.Options {
+	opts := zap.Options{
+		// Errors are logged with context, stacktrace is noise
+		StacktraceLevel: zapcore.PanicLevel,
+	}
+	var invalid []interface{}
+
+	logLevelValue := strings.ToLower(os.Getenv("LOG_LEVEL"))
+	switch logLevelValue {
+	case "trace":
+		opts.Level = zapcore.Level(-Trace)
+	case "debug":
+		opts.Level = zapcore.Level(-Debug)
+	case "", defaultLogLevel:
+		opts.Level = zapcore.InfoLevel
+	case "warn", "warning":
+		opts.Level = zapcore.WarnLevel
+	case "error":
+		opts.Level = zapcore.ErrorLevel
+	default:
+		opts.Level = zapcore.InfoLevel
+		invalid = append(invalid, "LOG_LEVEL", logLevelValue)
 	}
-	log.SetLevel(logLevel)
 
 	logFormatValue := strings.ToLower(os.Getenv("LOG_FORMAT"))
-	if logFormatValue != "text" && logFormatValue != "json" && logFormatValue != defaultLogFormat {
-		log.Debugf("Wrong log format set [%v], using default [%v]", logFormatValue, defaultLogFormat)
-		logFormatValue = defaultLogFormat
+	switch logFormatValue {
+	case "json":
+		zap.JSONEncoder()(&opts)
+	case "", defaultLogFormat:
+		zap.ConsoleEncoder()(&opts)
+	default:
+		zap.ConsoleEncoder()(&opts)
+		invalid = append(invalid, "LOG_FORMAT", logFormatValue)
 	}
-	if logFormatValue == "json" {
-		log.SetFormatter(&log.JSONFormatter{})
-	} else if logFormatValue == "text" {
-		log.SetFormatter(&log.TextFormatter{
-			FullTimestamp:          true,
-			DisableLevelTruncation: true,
-		})
-	} else if logFormatValue == defaultLogFormat {
-		log.SetFormatter(&nested.Formatter{
-			HideKeys:      true,
-			ShowFullLevel: true,
-		})
 
+	if len(invalid) > 0 {
+		zap.New(zap.UseFlagOptions(&opts)).WithName("Logger").Info("Wrong logger settings, using defaults", append(invalid, "level", defaultLogLevel, "format", defaultLogFormat)...)
 	}
+	return opts
+}
+
+// New - logger, configured from environment variables
+func New() logr.Logger {
+	opts := Options()
+	return zap.New(zap.UseFlagOptions(&opts))
 }
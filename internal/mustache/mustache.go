@@ -0,0 +1,396 @@
+// Package mustache validates and renders mustache templates of monitor trigger actions the way the alerting plugin does.
+// General purpose mustache libraries render unknown variables silently or report them only in rendered sections, and don't
+// know Elasticsearch toJson and join functions, while validation needs every variable checked against sample context,
+// including skipped sections and values, which are known only when monitor runs, like search results.
+//
+// Supported subset of mustache:
+//   - variables {{name}}, JSON escaped like Elasticsearch does by default, and unescaped {{{name}}} and {{&name}},
+//     with dotted names and list indexes (ctx.results.0.hits)
+//   - sections {{#name}}...{{/name}} over lists, objects and other truthy values, and inverted sections {{^name}}
+//   - comments {{! text}} and set delimiters {{=<% %>=}}
+//   - Elasticsearch functions {{#toJson}}name{{/toJson}} and {{#join}}name{{/join}}
+//
+// Partials, section arguments (e.g. join with custom delimiter) and url function are rejected as syntax errors
+package mustache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dynamic marks context value, which structure is unknown before monitor execution (e.g. search results).
+// Any variable inside dynamic value is considered as known and rendered as empty string.
+var Dynamic = dynamic{}
+
+type dynamic struct{}
+
+// Optional marks context object, which is null in some executions (e.g. ctx.alert of new alert), with names of its
+// documented fields. Documented fields are rendered as empty strings and reported as warnings, unless they are used
+// inside section of the object. Other fields are unknown
+type Optional []string
+
+// optionalScope - context of skipped section of optional object
+type optionalScope Optional
+
+func (o Optional) documents(name string) bool {
+	for _, field := range o {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+type nodeKind int
+
+const (
+	textNode nodeKind = iota
+	variableNode
+	sectionNode
+	invertedSectionNode
+)
+
+type node struct {
+	kind     nodeKind
+	text     string
+	name     string
+	line     int
+	raw      bool
+	children []*node
+}
+
+// Render parse mustache template and render it with passed context.
+// Returns error on syntax errors and variables, which can't be found in context. Documented fields of optional objects
+// are returned as warnings
+func Render(template string, context map[string]interface{}) (string, []string, error) {
+	nodes, err := parse(template)
+	if err != nil {
+		return "", nil, err
+	}
+	r := &renderer{stack: []interface{}{context}}
+	var out strings.Builder
+	if err := r.render(nodes, &out); err != nil {
+		return "", nil, err
+	}
+	if len(r.unknowns) > 0 {
+		return "", r.warnings, errors.New(strings.Join(r.unknowns, "; "))
+	}
+	return out.String(), r.warnings, nil
+}
+
+func parse(template string) ([]*node, error) {
+	openTag, closeTag := "{{", "}}"
+	root := &node{kind: sectionNode}
+	stack := []*node{root}
+	pos := 0
+	for pos < len(template) {
+		current := stack[len(stack)-1]
+		start := strings.Index(template[pos:], openTag)
+		if start < 0 {
+			current.children = append(current.children, &node{kind: textNode, text: template[pos:]})
+			break
+		}
+		start += pos
+		if start > pos {
+			current.children = append(current.children, &node{kind: textNode, text: template[pos:start]})
+		}
+		line := strings.Count(template[:start], "\n") + 1
+		tagStart := start + len(openTag)
+		tagCloseTag := closeTag
+		// Triple mustache {{{name}}}
+		if strings.HasPrefix(template[tagStart:], "{") && openTag == "{{" {
+			tagCloseTag = "}" + closeTag
+		}
+		end := strings.Index(template[tagStart:], tagCloseTag)
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unclosed tag %q", line, openTag)
+		}
+		end += tagStart
+		tag := strings.TrimSpace(template[tagStart:end])
+		pos = end + len(tagCloseTag)
+		if tag == "" {
+			return nil, fmt.Errorf("line %d: empty tag", line)
+		}
+
+		name := strings.TrimSpace(tag[1:])
+		switch tag[0] {
+		case '!':
+			// Comment
+		case '#', '^':
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty section name", line)
+			}
+			if strings.ContainsAny(name, " \t\n") {
+				return nil, fmt.Errorf("line %d: section arguments are not supported", line)
+			}
+			if name == "url" {
+				return nil, fmt.Errorf("line %d: url function is not supported", line)
+			}
+			kind := sectionNode
+			if tag[0] == '^' {
+				kind = invertedSectionNode
+			}
+			section := &node{kind: kind, name: name, line: line}
+			current.children = append(current.children, section)
+			stack = append(stack, section)
+		case '/':
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("line %d: unexpected closing tag %q", line, name)
+			}
+			if current.name != name {
+				return nil, fmt.Errorf("line %d: closing tag %q doesn't match section %q opened at line %d", line, name, current.name, current.line)
+			}
+			stack = stack[:len(stack)-1]
+		case '{', '&':
+			name = strings.TrimSpace(strings.TrimSuffix(name, "}"))
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty variable name", line)
+			}
+			current.children = append(current.children, &node{kind: variableNode, name: name, line: line, raw: true})
+		case '=':
+			delimiters := strings.Fields(strings.TrimSuffix(name, "="))
+			if len(delimiters) != 2 {
+				return nil, fmt.Errorf("line %d: wrong delimiters %q", line, tag)
+			}
+			openTag, closeTag = delimiters[0], delimiters[1]
+		case '>':
+			return nil, fmt.Errorf("line %d: partials are not supported", line)
+		default:
+			current.children = append(current.children, &node{kind: variableNode, name: tag, line: line})
+		}
+	}
+	if len(stack) > 1 {
+		unclosed := stack[len(stack)-1]
+		return nil, fmt.Errorf("line %d: unclosed section %q", unclosed.line, unclosed.name)
+	}
+	return root.children, nil
+}
+
+type renderer struct {
+	stack    []interface{}
+	unknowns []string
+	warnings []string
+}
+
+func (r *renderer) render(nodes []*node, out *strings.Builder) error {
+	for _, n := range nodes {
+		switch n.kind {
+		case textNode:
+			out.WriteString(n.text)
+		case variableNode:
+			if n.raw {
+				out.WriteString(format(r.lookup(n)))
+			} else {
+				out.WriteString(escapeJSON(format(r.lookup(n))))
+			}
+		case sectionNode, invertedSectionNode:
+			if err := r.renderSection(n, out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *renderer) renderSection(n *node, out *strings.Builder) error {
+	// Elasticsearch mustache functions, section content is a variable name
+	if n.kind == sectionNode && (n.name == "toJson" || n.name == "join") {
+		return r.renderFunction(n, out)
+	}
+	value := r.lookup(n)
+	truthy := isTruthy(value)
+	if n.kind == invertedSectionNode {
+		// Inverted section doesn't change context
+		if truthy {
+			return r.renderWith(nil, n.children, &strings.Builder{})
+		}
+		return r.renderWith(nil, n.children, out)
+	}
+	if !truthy {
+		// Section is not rendered, but its variables still must be valid. Items of empty list are unknown
+		var item interface{}
+		switch v := value.(type) {
+		case []interface{}:
+			item = Dynamic
+		case Optional:
+			item = optionalScope(v)
+		}
+		return r.renderWith(item, n.children, &strings.Builder{})
+	}
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			if err := r.renderWith(item, n.children, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return r.renderWith(value, n.children, out)
+}
+
+func (r *renderer) renderFunction(n *node, out *strings.Builder) error {
+	var content strings.Builder
+	for _, child := range n.children {
+		if child.kind != textNode {
+			return fmt.Errorf("line %d: %v function accepts only variable name", n.line, n.name)
+		}
+		content.WriteString(child.text)
+	}
+	value := r.lookup(&node{name: strings.TrimSpace(content.String()), line: n.line})
+	if n.name == "join" {
+		if list, ok := value.([]interface{}); ok {
+			items := make([]string, 0, len(list))
+			for _, item := range list {
+				items = append(items, format(item))
+			}
+			out.WriteString(strings.Join(items, ","))
+			return nil
+		}
+	}
+	out.WriteString(toJSON(value))
+	return nil
+}
+
+func (r *renderer) renderWith(value interface{}, nodes []*node, out *strings.Builder) error {
+	r.stack = append(r.stack, value)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+	return r.render(nodes, out)
+}
+
+// lookup find dotted name in context stack, starting from the innermost section. Unknown variable is nil
+func (r *renderer) lookup(n *node) interface{} {
+	if n.name == "." {
+		return r.stack[len(r.stack)-1]
+	}
+	parts := strings.Split(n.name, ".")
+	for i := len(r.stack) - 1; i >= 0; i-- {
+		if r.stack[i] == nil {
+			continue
+		}
+		value, found := child(r.stack[i], parts[0])
+		if !found {
+			continue
+		}
+		for _, part := range parts[1:] {
+			if optional, ok := value.(Optional); ok {
+				if !optional.documents(part) {
+					r.unknowns = appendOnce(r.unknowns, fmt.Sprintf("line %d: unknown variable %q", n.line, n.name))
+					return nil
+				}
+				r.warnings = appendOnce(r.warnings, fmt.Sprintf("line %d: variable %q may be empty", n.line, n.name))
+				return nil
+			}
+			if value, found = child(value, part); !found {
+				r.unknowns = appendOnce(r.unknowns, fmt.Sprintf("line %d: unknown variable %q", n.line, n.name))
+				return nil
+			}
+		}
+		return value
+	}
+	r.unknowns = appendOnce(r.unknowns, fmt.Sprintf("line %d: unknown variable %q", n.line, n.name))
+	return nil
+}
+
+func appendOnce(messages []string, message string) []string {
+	for _, existing := range messages {
+		if existing == message {
+			return messages
+		}
+	}
+	return append(messages, message)
+}
+
+func child(value interface{}, name string) (interface{}, bool) {
+	switch v := value.(type) {
+	case dynamic:
+		return Dynamic, true
+	case optionalScope:
+		if Optional(v).documents(name) {
+			return nil, true
+		}
+		return nil, false
+	case map[string]interface{}:
+		result, found := v[name]
+		return result, found
+	case []interface{}:
+		index, err := strconv.Atoi(name)
+		if err != nil || index < 0 || index >= len(v) {
+			return nil, false
+		}
+		return v[index], true
+	}
+	return nil, false
+}
+
+func isTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil, Optional, optionalScope:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case nil, dynamic, Optional, optionalScope:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		return toJSON(v)
+	}
+	return fmt.Sprint(value)
+}
+
+func toJSON(value interface{}) string {
+	switch value.(type) {
+	case dynamic, Optional, optionalScope:
+		return ""
+	}
+	result, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(result)
+}
+
+// escapeJSON - escape value like Elasticsearch mustache JSON encoder: quotes, backslashes and control characters
+func escapeJSON(value string) string {
+	var out strings.Builder
+	for _, c := range value {
+		switch c {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\b':
+			out.WriteString(`\b`)
+		case '\f':
+			out.WriteString(`\f`)
+		default:
+			if c < 0x20 {
+				fmt.Fprintf(&out, `\u%04x`, c)
+			} else {
+				out.WriteRune(c)
+			}
+		}
+	}
+	return out.String()
+}
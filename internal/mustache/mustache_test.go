@@ -0,0 +1,78 @@
+package mustache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	context := map[string]interface{}{
+		"ctx": map[string]interface{}{
+			"monitor": map[string]interface{}{"name": "errors", "enabled": true},
+			"results": []interface{}{Dynamic},
+			"actions": []interface{}{
+				map[string]interface{}{"name": "slack"},
+				map[string]interface{}{"name": "email"},
+			},
+			"count": float64(10),
+			"text":  "say \"hi\"\n",
+			"alert": Optional{"id", "state"},
+			"error": nil,
+		},
+	}
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		warning  string
+		err      string
+	}{
+		{"variable", "Monitor {{ctx.monitor.name}} fired", "Monitor errors fired", "", ""},
+		{"triple mustache", "{{{ctx.monitor.name}}}", "errors", "", ""},
+		{"escaped variable", "{{ctx.text}}", `say \"hi\"\n`, "", ""},
+		{"unescaped variable", "{{{ctx.text}}}|{{&ctx.text}}", "say \"hi\"\n|say \"hi\"\n", "", ""},
+		{"number", "{{ctx.count}} errors", "10 errors", "", ""},
+		{"list index", "{{ctx.actions.1.name}}", "email", "", ""},
+		{"section over list", "{{#ctx.actions}}{{name}};{{/ctx.actions}}", "slack;email;", "", ""},
+		{"inverted section", "{{^ctx.alert}}new{{/ctx.alert}}", "new", "", ""},
+		{"null value", "{{ctx.error}}", "", "", ""},
+		{"optional field", "{{ctx.alert.id}}", "", `line 1: variable "ctx.alert.id" may be empty`, ""},
+		{"optional field in section", "{{#ctx.alert}}{{id}} {{state}}{{/ctx.alert}}", "", "", ""},
+		{"dynamic results", "{{ctx.results.0.hits.total.value}}", "", "", ""},
+		{"comment", "a{{! comment }}b", "ab", "", ""},
+		{"delimiters", "{{=<% %>=}}<% ctx.monitor.name %>", "errors", "", ""},
+		{"toJson", "{{#toJson}}ctx.monitor{{/toJson}}", `{"enabled":true,"name":"errors"}`, "", ""},
+		{"unknown variable", "{{ctx.monitor.nmae}}!", "", "", `line 1: unknown variable "ctx.monitor.nmae"`},
+		{"unknown root", "{{monitor.name}}", "", "", `unknown variable "monitor.name"`},
+		{"unknown in skipped section", "{{#ctx.alert}}{{ctx.monitr}}{{/ctx.alert}}", "", "", `unknown variable "ctx.monitr"`},
+		{"undocumented optional field", "{{ctx.alert.nmae}}", "", "", `unknown variable "ctx.alert.nmae"`},
+		{"undocumented field in optional section", "{{#ctx.alert}}{{nmae}}{{/ctx.alert}}", "", "", `unknown variable "nmae"`},
+		{"field of null value", "{{ctx.error.reason}}", "", "", `unknown variable "ctx.error.reason"`},
+		{"partial", "{{> header}}", "", "", "partials are not supported"},
+		{"join delimiter", "{{#join delimiter=' '}}ctx.actions{{/join delimiter=' '}}", "", "", "section arguments are not supported"},
+		{"url function", "{{#url}}ctx.monitor.name{{/url}}", "", "", "url function is not supported"},
+		{"unclosed tag", "{{ctx.monitor.name", "", "", "unclosed tag"},
+		{"unclosed section", "line\n{{#ctx.actions}}", "", "", `line 2: unclosed section "ctx.actions"`},
+		{"mismatched section", "{{#ctx.actions}}{{/ctx.monitor}}", "", "", "doesn't match section"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, warnings, err := Render(test.template, context)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, result)
+			}
+			if warning := strings.Join(warnings, "; "); !strings.Contains(warning, test.warning) || (test.warning == "") != (warning == "") {
+				t.Errorf("expected warning %q, got %q", test.warning, warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	tracesPath     = "/v1/traces"
+	scopeName      = "github.com/aberestyak/elasticsearch-security-operator"
+	exportInterval = 5 * time.Second
+	exportTimeout  = 10 * time.Second
+	maxBatchSize   = 512
+	maxQueueSize   = 2048
+)
+
+var (
+	tracingLogger = logf.Log.WithName("Tracing")
+	// defaultExporter is nil, when tracing is disabled
+	defaultExporter *Exporter
+)
+
+// Options - tracing configuration
+type Options struct {
+	// Endpoint - base URL of OTLP/HTTP receiver, for example http://otel-collector:4318
+	Endpoint string
+	// ServiceName - service.name resource attribute
+	ServiceName string
+	// SampleRatio - part of traces to export, from 0 to 1
+	SampleRatio float64
+}
+
+// Exporter - send finished spans in batches to OTLP/HTTP receiver with JSON encoding
+type Exporter struct {
+	url         string
+	serviceName string
+	sampleRatio float64
+	client      *http.Client
+
+	mu      sync.Mutex
+	queue   []*Span
+	dropped int
+	flush   chan struct{}
+}
+
+// Init - enable tracing. Returned exporter must be started to send spans
+func Init(opts Options) *Exporter {
+	defaultExporter = &Exporter{
+		url:         strings.TrimSuffix(opts.Endpoint, "/") + tracesPath,
+		serviceName: opts.ServiceName,
+		sampleRatio: opts.SampleRatio,
+		client:      &http.Client{Timeout: exportTimeout},
+		flush:       make(chan struct{}, 1),
+	}
+	return defaultExporter
+}
+
+// Start - export spans until context is done, then export the rest. Implements manager.Runnable
+func (e *Exporter) Start(ctx context.Context) error {
+	tracingLogger.Info("Exporting traces", "url", e.url, "sampleRatio", e.sampleRatio)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.export()
+			return nil
+		case <-ticker.C:
+			e.export()
+		case <-e.flush:
+			e.export()
+		}
+	}
+}
+
+// NeedLeaderElection - spans are exported by every replica
+func (e *Exporter) NeedLeaderElection() bool {
+	return false
+}
+
+func (e *Exporter) enqueue(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.queue) >= maxQueueSize {
+		e.dropped++
+		return
+	}
+	e.queue = append(e.queue, span)
+	if len(e.queue) >= maxBatchSize {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *Exporter) export() {
+	e.mu.Lock()
+	queue, dropped := e.queue, e.dropped
+	e.queue, e.dropped = nil, 0
+	e.mu.Unlock()
+	if dropped > 0 {
+		tracingLogger.Info("Export queue is full, spans are dropped", "dropped", dropped)
+	}
+	for len(queue) > 0 {
+		batch := queue
+		if len(batch) > maxBatchSize {
+			batch = queue[:maxBatchSize]
+		}
+		queue = queue[len(batch):]
+		if err := e.send(batch); err != nil {
+			tracingLogger.Error(err, "Error when exporting spans", "url", e.url, "spans", len(batch))
+		}
+	}
+}
+
+func (e *Exporter) send(spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := e.client.Do(request)
+	if err != nil {
+		return err
+	}
+	responseBody, _ := ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("receiver returned %v: %v", response.StatusCode, string(responseBody))
+	}
+	return nil
+}
+
+// OTLP JSON encoding of ExportTraceServiceRequest
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *Exporter) request(spans []*Span) otlpRequest {
+	scopeSpans := otlpScopeSpans{Scope: otlpScope{Name: scopeName}}
+	for _, span := range spans {
+		scopeSpans.Spans = append(scopeSpans.Spans, span.otlp())
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{newAttribute("service.name", e.serviceName)}},
+		ScopeSpans: []otlpScopeSpans{scopeSpans},
+	}}}
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: s.statusCode, Message: s.statusMessage},
+	}
+	if s.parentSpanID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentSpanID[:])
+	}
+	for _, attr := range s.attributes {
+		span.Attributes = append(span.Attributes, newAttribute(attr.key, attr.value))
+	}
+	return span
+}
+
+func newAttribute(key string, value interface{}) otlpAttribute {
+	var v otlpValue
+	switch typed := value.(type) {
+	case bool:
+		v.BoolValue = &typed
+	case int:
+		i := strconv.Itoa(typed)
+		v.IntValue = &i
+	case int64:
+		i := strconv.FormatInt(typed, 10)
+		v.IntValue = &i
+	case float64:
+		v.DoubleValue = &typed
+	case string:
+		v.StringValue = &typed
+	default:
+		s := fmt.Sprint(typed)
+		v.StringValue = &s
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
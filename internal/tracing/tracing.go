@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TraceParentHeader - W3C trace context header, propagated to Elasticsearch
+const TraceParentHeader = "traceparent"
+
+// SpanKind - role of span in trace, values of OTLP enum
+type SpanKind int
+
+const (
+	// SpanKindInternal - operation inside operator, such as reconcile
+	SpanKindInternal SpanKind = 1
+	// SpanKindClient - outgoing request
+	SpanKindClient SpanKind = 3
+)
+
+// statusCodeError - OTLP status code of failed span
+const statusCodeError = 2
+
+// Span - timed operation. Methods of nil span are no-op, so callers don't check if tracing is enabled
+type Span struct {
+	traceID      [16]byte
+	spanID       [8]byte
+	parentSpanID [8]byte
+	sampled      bool
+	name         string
+	kind         SpanKind
+	start        time.Time
+
+	mu            sync.Mutex
+	end           time.Time
+	attributes    []attribute
+	statusCode    int
+	statusMessage string
+	ended         bool
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+type spanKey struct{}
+
+// Start - start span, child of span in context, if any. Returns nil span, if tracing is disabled
+func Start(ctx context.Context, name string, kind SpanKind, keysAndValues ...interface{}) (context.Context, *Span) {
+	if defaultExporter == nil {
+		return ctx, nil
+	}
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	if parent := FromContext(ctx); parent != nil {
+		span.traceID = parent.traceID
+		span.parentSpanID = parent.spanID
+		span.sampled = parent.sampled
+	} else {
+		randomID(span.traceID[:])
+		span.sampled = mathrand.Float64() < defaultExporter.sampleRatio
+	}
+	randomID(span.spanID[:])
+	span.SetAttributes(keysAndValues...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartChild - start span only inside existing trace, so background requests, such as readiness checks, don't create traces
+func StartChild(ctx context.Context, name string, kind SpanKind, keysAndValues ...interface{}) (context.Context, *Span) {
+	if FromContext(ctx) == nil {
+		return ctx, nil
+	}
+	return Start(ctx, name, kind, keysAndValues...)
+}
+
+// FromContext - current span, nil if there is no span
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithSpan - context with span, so work done on behalf of another request, like batched request, is traced in its trace
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// Inject - add traceparent header of current span to request headers
+func Inject(ctx context.Context, header http.Header) {
+	if span := FromContext(ctx); span != nil {
+		header.Set(TraceParentHeader, span.traceParent())
+	}
+}
+
+// TraceID - hex trace ID, empty if there is no span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// SetAttributes - add attributes as key/value pairs, like logr
+func (s *Span) SetAttributes(keysAndValues ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		s.attributes = append(s.attributes, attribute{key: fmt.Sprint(keysAndValues[i]), value: keysAndValues[i+1]})
+	}
+}
+
+// SetError - mark span as failed
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusCode = statusCodeError
+	s.statusMessage = message
+}
+
+// End - finish span and queue it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	if s.sampled && defaultExporter != nil {
+		defaultExporter.enqueue(s)
+	}
+}
+
+func (s *Span) traceParent() string {
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%x-%x-%v", s.traceID, s.spanID, flags)
+}
+
+func randomID(id []byte) {
+	if _, err := rand.Read(id); err != nil {
+		_, _ = mathrand.Read(id)
+	}
+}
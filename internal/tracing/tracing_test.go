@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	var received otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != tracesPath || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %v %v", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("request body is not OTLP JSON: %v", err)
+		}
+	}))
+	defer server.Close()
+	exporter := Init(Options{Endpoint: server.URL + "/", ServiceName: "test", SampleRatio: 1})
+	defer func() { defaultExporter = nil }()
+
+	if ctx, span := StartChild(context.Background(), "orphan", SpanKindClient); span != nil || FromContext(ctx) != nil {
+		t.Fatal("span without parent is started")
+	}
+	ctx, parent := Start(context.Background(), "Role.Reconcile", SpanKindInternal, "name", "test")
+	childCtx, child := StartChild(ctx, "GET role", SpanKindClient, "http.response.status_code", 404)
+	header := http.Header{}
+	Inject(childCtx, header)
+	traceParent := regexp.MustCompile(`^00-` + parent.TraceID() + `-[0-9a-f]{16}-01$`)
+	if !traceParent.MatchString(header.Get(TraceParentHeader)) {
+		t.Fatalf("unexpected traceparent %q", header.Get(TraceParentHeader))
+	}
+	child.SetError("Not Found")
+	child.End()
+	parent.End()
+	exporter.export()
+
+	if len(received.ResourceSpans) != 1 || len(received.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %+v", received)
+	}
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %v", len(spans))
+	}
+	if spans[0].TraceID != spans[1].TraceID || spans[0].ParentSpanID != spans[1].SpanID || spans[1].ParentSpanID != "" {
+		t.Fatalf("child span is not linked to parent: %+v", spans)
+	}
+	if spans[0].Status.Code != statusCodeError || *spans[0].Attributes[0].Value.IntValue != "404" {
+		t.Fatalf("unexpected child span %+v", spans[0])
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "Role.Reconcile", SpanKindInternal)
+	header := http.Header{}
+	Inject(ctx, header)
+	span.SetAttributes("name", "test")
+	span.SetError("error")
+	span.End()
+	if span != nil || header.Get(TraceParentHeader) != "" {
+		t.Fatal("span is started, when tracing is disabled")
+	}
+}
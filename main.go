@@ -30,10 +30,13 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	securityv1alpha1 "github.com/aberestyak/elasticsearch-security-operator/api/v1alpha1"
+	"github.com/aberestyak/elasticsearch-security-operator/config"
 	"github.com/aberestyak/elasticsearch-security-operator/controllers"
 	"github.com/aberestyak/elasticsearch-security-operator/internal/logger"
+	"github.com/aberestyak/elasticsearch-security-operator/internal/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -43,7 +46,6 @@ var (
 )
 
 func init() {
-	logger.Init()
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(securityv1alpha1.AddToScheme(scheme))
@@ -59,9 +61,7 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
-	opts := zap.Options{
-		Development: true,
-	}
+	opts := logger.Options()
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
@@ -80,41 +80,121 @@ func main() {
 		os.Exit(1)
 	}
 
+	if config.AppConfig.BulkRequestsEnabled {
+		controllers.EnableBulkRequests(config.AppConfig.BulkRequestsWindow, config.AppConfig.BulkRequestsMaxSize)
+	}
+
+	// Audit trail of changes in Elasticsearch, nil if disabled
+	var changeLog *controllers.ChangeLog
+	if config.AppConfig.ChangeLogEnabled {
+		changeLog = &controllers.ChangeLog{
+			Client:    mgr.GetClient(),
+			Retention: config.AppConfig.ChangeLogRetention,
+		}
+		if err := mgr.Add(changeLog); err != nil {
+			setupLog.Error(err, "unable to set up change log")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controllers.AlertReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Alert"),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("Alert"),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("alert-controller"),
+		ChangeLog: changeLog,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Alert")
 		os.Exit(1)
 	}
+	if err = (&controllers.ActionGroupReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("ActionGroup"),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("actiongroup-controller"),
+		ChangeLog: changeLog,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ActionGroup")
+		os.Exit(1)
+	}
 	if err = (&controllers.RoleReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Role"),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("Role"),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("role-controller"),
+		ChangeLog: changeLog,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Role")
 		os.Exit(1)
 	}
+	if err = (&controllers.RoleTemplateReconciler{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("RoleTemplate"),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("roletemplate-controller"),
+		ChangeLog: changeLog,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RoleTemplate")
+		os.Exit(1)
+	}
 	if err = (&controllers.UserReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("User"),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("controllers").WithName("User"),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("user-controller"),
+		ChangeLog: changeLog,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "User")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if config.AppConfig.RejectUnknownActions {
+			securityv1alpha1.UnknownActionsFunc = controllers.UnknownActions
+		}
+		securityv1alpha1.DefaultClusterPermissions = config.AppConfig.DefaultClusterPermissions
+		if err = (&securityv1alpha1.Alert{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Alert")
+			os.Exit(1)
+		}
+		if err = (&securityv1alpha1.Role{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Role")
+			os.Exit(1)
+		}
+		if err = (&securityv1alpha1.User{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "User")
+			os.Exit(1)
+		}
+	}
+	// Count managed resources by state on every scrape
+	metrics.Registry.MustRegister(&controllers.ManagedObjectsCollector{Client: mgr.GetClient()})
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+	// Operator is ready, when it can manage elasticsearch objects
+	readinessCheck := &controllers.ReadinessCheck{
+		Controllers: []string{"Alert", "ActionGroup", "Role", "RoleTemplate", "User"},
+		TTL:         config.AppConfig.ReadinessCacheTTL,
+	}
+	if err := mgr.AddReadyzCheck("elasticsearch", readinessCheck.Check); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
 
+	if config.AppConfig.TracingEnabled {
+		exporter := tracing.Init(tracing.Options{
+			Endpoint:    config.AppConfig.TracingEndpoint,
+			ServiceName: config.AppConfig.TracingServiceName,
+			SampleRatio: config.AppConfig.TracingSampleRatio,
+		})
+		if err := mgr.Add(exporter); err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")